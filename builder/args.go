@@ -11,16 +11,49 @@ type Args struct {
 	DataFiles     []string
 	SourceFolders []string
 	TargetFolder  string
+	// ListMerge strategy for lists in data files, defaults to ListMergeReplace
+	ListMerge ListMergeStrategy
+	// ListMergeKey identifies maps in lists with ListMergeByKey, defaults to DefaultListMergeKey
+	ListMergeKey string
+	// Overrides are set in order on top of the data files
+	Overrides []DataOverride
+	// EnvPrefix maps environment variables with this prefix into the data before the overrides are applied
+	EnvPrefix string
+	// MissingKey policy for templates, defaults to MissingKeyError
+	MissingKey MissingKeyPolicy
+	// Validate rendered json, yaml and toml files and check them against their schemas
+	Validate bool
 }
 
 func GetBuilderArgs(args []string) (ba *Args, err error) {
-	ba = &Args{
-		TargetFolder: "",
-	}
 	if len(args) < 2 {
 		return nil, errors.New("i need at least a source folder and a target folder")
 	}
-	for _, arg := range args[0 : len(args)-1] {
+	ba, err = getArgs(args[0 : len(args)-1])
+	if err != nil {
+		return nil, err
+	}
+	ba.TargetFolder = args[len(args)-1]
+	return ba, nil
+}
+
+// GetLintArgs data files and source folders without a target folder
+func GetLintArgs(args []string) (ba *Args, err error) {
+	ba, err = getArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	if len(ba.SourceFolders) == 0 {
+		return nil, errors.New("i need at least a source folder")
+	}
+	return ba, nil
+}
+
+func getArgs(args []string) (ba *Args, err error) {
+	ba = &Args{
+		TargetFolder: "",
+	}
+	for _, arg := range args {
 		f, err := os.Stat(arg)
 		if err != nil {
 			return nil, errors.New("arg: \"" + arg + "\" is not a file / folder")
@@ -28,13 +61,12 @@ func GetBuilderArgs(args []string) (ba *Args, err error) {
 		if f.IsDir() {
 			ba.SourceFolders = append(ba.SourceFolders, arg)
 		} else {
-			if strings.HasSuffix(arg, ".json") || strings.HasSuffix(arg, ".yml") || strings.HasSuffix(arg, ".yaml") {
+			if _, ok := getDataReader(arg); ok {
 				ba.DataFiles = append(ba.DataFiles, arg)
 			} else {
-				return nil, errors.New("can not use the given data file suffix has to be .yml, .yaml or .json")
+				return nil, errors.New("can not use the given data file suffix has to be one of " + strings.Join(DataFileExtensions(), ", "))
 			}
 		}
 	}
-	ba.TargetFolder = args[len(args)-1]
 	return ba, nil
 }
@@ -1,7 +1,6 @@
 package builder
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -10,41 +9,68 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
-
-	"gopkg.in/yaml.v2"
 )
 
 // Build
 func Build(args *Args) (result *ProcessingResult, err error) {
+	result, _, err = build(args)
+	return result, err
+}
+
+// build returns the result and the renderer it was rendered with
+func build(args *Args) (result *ProcessingResult, r *renderer, err error) {
 	fmt.Println(line)
 	fmt.Println("building")
 	fmt.Println("data files     :", strings.Join(args.DataFiles, ", "))
 	fmt.Println("source folders :", strings.Join(args.SourceFolders, ", "))
 	fmt.Println("target folder  :", args.TargetFolder)
 	fmt.Println(line)
-	data, err := readData(args.DataFiles)
+	data, err := readData(args)
+	if err != nil {
+		return nil, nil, errors.New("could not read data from: " + strings.Join(args.DataFiles, ", ") + " :: " + err.Error())
+	}
+	partials, err := loadPartials(args.SourceFolders)
+	if err != nil {
+		return nil, nil, errors.New("could not load partials: " + err.Error())
+	}
+	missingKey, err := getMissingKeyPolicy(args.MissingKey)
 	if err != nil {
-		return nil, errors.New("could not read data from: " + strings.Join(args.DataFiles, ", ") + " :: " + err.Error())
+		return nil, nil, err
+	}
+	r = &renderer{
+		data:        data,
+		partials:    partials,
+		missingKey:  missingKey,
+		missingKeys: &missingKeyReport{},
 	}
 
-	var results []*ProcessingResult
+	var (
+		results []*ProcessingResult
+		errs    Errors
+	)
 
 	if len(args.SourceFolders) == 0 {
-		return nil, errors.New("there has to be at least one source folder")
+		return nil, nil, errors.New("there has to be at least one source folder")
 	}
 	for _, sourceFolder := range args.SourceFolders {
 		fmt.Println(line)
 		fmt.Println("processing folder", sourceFolder)
 		fmt.Println(line)
 
-		result, err := processFolder(sourceFolder, data)
+		result, err := processFolder(sourceFolder, r)
 		if err != nil {
-			return nil, err
+			// keep going to report the errors of all source folders at once
+			errs = errs.add(err)
+			continue
 		}
 		results = append(results, result)
 	}
+	errs = errs.add(r.missingKeys.err())
+	if err := errs.err(); err != nil {
+		return nil, nil, err
+	}
 	if len(results) == 0 {
-		return nil, nil
+		return nil, nil, nil
 	}
 
 	result = results[0]
@@ -53,8 +79,15 @@ func Build(args *Args) (result *ProcessingResult, err error) {
 			result.Merge(r)
 		}
 	}
+	result.printSkipped()
+	if args.Validate {
+		err = result.Validate()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
 
-	return result, nil
+	return result, r, nil
 }
 
 const line = "-------------------------------------------------------------------------------"
@@ -84,41 +117,28 @@ func WriteProcessingResult(targetFolder string, result *ProcessingResult) error
 	for file, processingResult := range result.Files {
 		i++
 		file = path.Join(targetFolder, file)
-		fmt.Println(processingResult.info.Mode().Perm(), i, file)
-		err := ioutil.WriteFile(file, processingResult.bytes, processingResult.info.Mode().Perm())
+		fmt.Println(processingResult.mode, i, file)
+		err := ioutil.WriteFile(file, processingResult.bytes, processingResult.mode)
 		if err != nil {
 			return err
 		}
-	}
-	return nil
-}
-
-func readData(files []string) (interface{}, error) {
-	if len(files) == 0 {
-		return nil, nil
-	}
-	data := make(map[string]interface{})
-
-	for _, file := range files {
-		fileData := make(map[string]interface{})
-
-		dataBytes, err := ioutil.ReadFile(file)
+		// existing files keep their mode otherwise
+		err = os.Chmod(file, processingResult.mode)
 		if err != nil {
-			return nil, errors.New("could not read data file: " + err.Error())
-		}
-		if strings.HasSuffix(file, ".json") {
-			err = json.Unmarshal(dataBytes, &fileData)
-		} else if strings.HasSuffix(file, ".yml") || strings.HasSuffix(file, ".yaml") {
-			err = yaml.Unmarshal(dataBytes, &fileData)
-		} else {
-			return nil, errors.New("unsupported data file format i need .json, .yml or .yaml")
+			return err
 		}
-
-		for k, v := range fileData {
-			data[k] = v
+		if processingResult.owner != "" || processingResult.group != "" {
+			uid, gid, err := getOwnership(processingResult.owner, processingResult.group)
+			if err != nil {
+				return errors.New("could not change owner of " + file + " : " + err.Error())
+			}
+			err = os.Chown(file, uid, gid)
+			if err != nil {
+				return err
+			}
 		}
 	}
-	return data, nil
+	return nil
 }
 
 func getCopy(root string) (copy []string) {
@@ -133,7 +153,7 @@ func getStuff(root, name string) []string {
 		lines := strings.Split(string(stuffBytes), "\n")
 		for _, line := range lines {
 			trimmedLine := strings.TrimSpace(line)
-			if len(trimmedLine) > 0 {
+			if len(trimmedLine) > 0 && !strings.HasPrefix(trimmedLine, "#") {
 				stuff = append(stuff, trimmedLine)
 			}
 		}
@@ -143,18 +163,9 @@ func getStuff(root, name string) []string {
 
 func getIgnore(root string) (ignore []string) {
 	ignore = []string{".bobignore", ".bobcopy"}
-	return append(ignore, getStuff(root, ".bobignore")...)
-}
-
-func fileIsIgnored(root string, p string, ignore []string) bool {
-	prefix := root + string(os.PathSeparator)
-	trimmedPath := strings.TrimPrefix(p, prefix)
-	for _, ignored := range ignore {
-		if trimmedPath == ignored {
-			return true
-		}
-	}
-	return false
+	ignore = append(ignore, getStuff(root, ".bobignore")...)
+	// last, so that they can not be re-included
+	return append(ignore, folderConfigName, "/"+partialsFolder+"/")
 }
 
 func getFiles(root string, ignore []string) (files []string, err error) {
@@ -223,12 +234,19 @@ func walk(root string, ignore []string, filter func(path string, fileInfo os.Fil
 func filterFiles(root string, ignore []string, filter func(path string, fileInfo os.FileInfo) bool) ([]string, error) {
 	var files []string
 	prefix := root + string(os.PathSeparator)
+	ignored := newPatternMatcher(root, ignore, ".bobignore")
 	err := walk(root, ignore, func(path string, fileInfo os.FileInfo) (descend bool) {
-		if filter(path, fileInfo) && !fileIsIgnored(root, path, ignore) {
-			p := strings.TrimPrefix(path, prefix)
-			files = append(files, p)
+		isDir := false
+		if targetInfo, err := resolve(fileInfo, path); err == nil {
+			isDir = targetInfo.IsDir()
+		}
+		if ignored.matches(strings.TrimPrefix(path, prefix), isDir) {
+			return false
+		}
+		if filter(path, fileInfo) {
+			files = append(files, strings.TrimPrefix(path, prefix))
 		}
-		return !fileIsIgnored(root, path, ignore)
+		return true
 	})
 	sort.Strings(files)
 	return files, err
@@ -61,7 +61,7 @@ func TestProcess(t *testing.T) {
 	jsonBytes, err := ioutil.ReadFile(GetExample("data.json"))
 	panicOnErr(err)
 	panicOnErr(json.Unmarshal(jsonBytes, &data))
-	r, err := processFolder(exampleA, data)
+	r, err := processFolder(exampleA, &renderer{data: data})
 	if err != nil {
 		panic(err)
 	}
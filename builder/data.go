@@ -0,0 +1,305 @@
+package builder
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ListMergeStrategy how lists from different data files are merged
+type ListMergeStrategy string
+
+const (
+	// ListMergeReplace lists from later data files replace earlier ones
+	ListMergeReplace ListMergeStrategy = "replace"
+	// ListMergeAppend lists from later data files are appended to earlier ones
+	ListMergeAppend ListMergeStrategy = "append"
+	// ListMergeByKey maps in lists are merged, when they have the same value for the list merge key
+	ListMergeByKey ListMergeStrategy = "merge-by-key"
+)
+
+// DefaultListMergeKey is used to identify maps in lists with ListMergeByKey, if no other key was given
+const DefaultListMergeKey = "name"
+
+// ReadData reads and merges all data files of the args, just like a build does
+func ReadData(args *Args) (interface{}, error) {
+	return readData(args)
+}
+
+func readData(args *Args) (interface{}, error) {
+	if len(args.DataFiles) == 0 && len(args.Overrides) == 0 && args.EnvPrefix == "" {
+		return nil, nil
+	}
+	strategy := args.ListMerge
+	switch strategy {
+	case "":
+		strategy = ListMergeReplace
+	case ListMergeReplace, ListMergeAppend, ListMergeByKey:
+	default:
+		return nil, fmt.Errorf("unknown list merge strategy %q, use %q, %q or %q", strategy, ListMergeReplace, ListMergeAppend, ListMergeByKey)
+	}
+	key := args.ListMergeKey
+	if key == "" {
+		key = DefaultListMergeKey
+	}
+	data := make(map[string]interface{})
+
+	for _, file := range args.DataFiles {
+		dataBytes, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, errors.New("could not read data file: " + err.Error())
+		}
+		reader, ok := getDataReader(file)
+		if !ok {
+			return nil, errors.New("unsupported data file format i need one of " + strings.Join(DataFileExtensions(), ", "))
+		}
+		fileData, err := reader(dataBytes)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse data file %q: %q", file, err)
+		}
+
+		mergeData(data, normalizeData(fileData), strategy, key)
+	}
+	overrides := append(getEnvOverrides(args.EnvPrefix, os.Environ()), args.Overrides...)
+	for _, override := range overrides {
+		keys := strings.Split(override.Path, ".")
+		for _, key := range keys {
+			if key == "" {
+				return nil, fmt.Errorf("invalid path %q in data override", override.Path)
+			}
+		}
+		data = setData(data, keys, copyData(normalizeData(override.Value)), override.IgnoreCase).(map[string]interface{})
+	}
+	return data, nil
+}
+
+// DataOverride sets a value in the data after all data files have been read
+type DataOverride struct {
+	// Path of dot separated keys like servers.webApp.port, numeric keys are indexes in existing lists
+	Path  string
+	Value interface{}
+	// IgnoreCase matches keys in the data case insensitive
+	IgnoreCase bool
+}
+
+func splitOverride(override string) (path, value string, err error) {
+	parts := strings.SplitN(override, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return "", "", fmt.Errorf("invalid data override %q, expected path.to.key=value", override)
+	}
+	return parts[0], parts[1], nil
+}
+
+// parseValue types decimal integers, floats and true and false, that render exactly as they were given. Everything
+// else stays a string, so that values like 0644, 1.10 or no are not changed.
+func parseValue(value string) interface{} {
+	switch value {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	if i, err := strconv.Atoi(value); err == nil && strconv.Itoa(i) == value {
+		return i
+	}
+	if f, err := strconv.ParseFloat(value, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) && fmt.Sprint(f) == value {
+		return f
+	}
+	return value
+}
+
+// ParseSetOverride parses path.to.key=value, see parseValue for how the value is typed
+func ParseSetOverride(override string) (DataOverride, error) {
+	path, value, err := splitOverride(override)
+	return DataOverride{Path: path, Value: parseValue(value)}, err
+}
+
+// ParseSetJSONOverride parses path.to.key=json
+func ParseSetJSONOverride(override string) (DataOverride, error) {
+	path, value, err := splitOverride(override)
+	if err != nil {
+		return DataOverride{}, err
+	}
+	var parsed interface{}
+	err = json.Unmarshal([]byte(value), &parsed)
+	if err != nil {
+		return DataOverride{}, fmt.Errorf("invalid json in data override %q: %q", override, err)
+	}
+	return DataOverride{Path: path, Value: parsed}, nil
+}
+
+// ParseSetFileOverride parses path.to.key=path/to/file and sets the contents of the file as a string
+func ParseSetFileOverride(override string) (DataOverride, error) {
+	path, filename, err := splitOverride(override)
+	if err != nil {
+		return DataOverride{}, err
+	}
+	fileBytes, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return DataOverride{}, fmt.Errorf("could not read file for data override %q: %q", override, err)
+	}
+	return DataOverride{Path: path, Value: string(fileBytes)}, nil
+}
+
+// getEnvOverrides maps environment variables like PREFIX_servers__webApp__port=80 to servers.webApp.port, keys are
+// separated by double underscores and matched case insensitive
+func getEnvOverrides(prefix string, environ []string) (overrides []DataOverride) {
+	if prefix == "" {
+		return nil
+	}
+	sort.Strings(environ)
+	for _, env := range environ {
+		parts := strings.SplitN(env, "=", 2)
+		if len(parts) != 2 || !strings.HasPrefix(parts[0], prefix) || len(parts[0]) == len(prefix) {
+			continue
+		}
+		overrides = append(overrides, DataOverride{
+			Path:       strings.Replace(strings.TrimPrefix(parts[0], prefix), "__", ".", -1),
+			Value:      parseValue(parts[1]),
+			IgnoreCase: true,
+		})
+	}
+	return overrides
+}
+
+// setData sets the value at the path of keys and creates maps on the way, where necessary
+func setData(data interface{}, keys []string, value interface{}, ignoreCase bool) interface{} {
+	if len(keys) == 0 {
+		return value
+	}
+	if list, ok := data.([]interface{}); ok {
+		if i, err := strconv.Atoi(keys[0]); err == nil && i >= 0 && i < len(list) {
+			list[i] = setData(list[i], keys[1:], value, ignoreCase)
+			return list
+		}
+	}
+	m, ok := data.(map[string]interface{})
+	if !ok {
+		m = map[string]interface{}{}
+	}
+	key := keys[0]
+	if ignoreCase {
+		for existingKey := range m {
+			if strings.EqualFold(existingKey, key) {
+				key = existingKey
+				break
+			}
+		}
+	}
+	m[key] = setData(m[key], keys[1:], value, ignoreCase)
+	return m
+}
+
+// copyData copies maps and lists, so that overrides can be applied again and again
+func copyData(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[key] = copyData(value)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, value := range v {
+			l[i] = copyData(value)
+		}
+		return l
+	}
+	return value
+}
+
+// normalizeData turns the map[interface{}]interface{} maps, that yaml gives us into map[string]interface{}, so that
+// data from all formats can be merged and marshalled to json
+func normalizeData(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[fmt.Sprint(key)] = normalizeData(value)
+		}
+		return m
+	case map[string]interface{}:
+		for key, value := range v {
+			v[key] = normalizeData(value)
+		}
+		return v
+	case []interface{}:
+		for i, value := range v {
+			v[i] = normalizeData(value)
+		}
+		return v
+	case []map[string]interface{}:
+		l := make([]interface{}, len(v))
+		for i, value := range v {
+			l[i] = normalizeData(value)
+		}
+		return l
+	}
+	return value
+}
+
+// mergeData merges maps recursively, lists according to the strategy and replaces everything else
+func mergeData(dst, src interface{}, strategy ListMergeStrategy, key string) interface{} {
+	switch srcValue := src.(type) {
+	case map[string]interface{}:
+		dstMap, ok := dst.(map[string]interface{})
+		if !ok {
+			return src
+		}
+		for k, v := range srcValue {
+			dstMap[k] = mergeData(dstMap[k], v, strategy, key)
+		}
+		return dstMap
+	case []interface{}:
+		dstList, ok := dst.([]interface{})
+		if !ok {
+			return src
+		}
+		switch strategy {
+		case ListMergeAppend:
+			return append(append([]interface{}{}, dstList...), srcValue...)
+		case ListMergeByKey:
+			return mergeListByKey(dstList, srcValue, strategy, key)
+		}
+	}
+	return src
+}
+
+func mergeListByKey(dst, src []interface{}, strategy ListMergeStrategy, key string) []interface{} {
+	merged := append([]interface{}{}, dst...)
+	for _, srcItem := range src {
+		found := false
+		if srcKey, ok := listMergeKey(srcItem, key); ok {
+			for i, dstItem := range merged {
+				if dstKey, ok := listMergeKey(dstItem, key); ok && dstKey == srcKey {
+					merged[i] = mergeData(dstItem, srcItem, strategy, key)
+					found = true
+					break
+				}
+			}
+		}
+		if !found {
+			merged = append(merged, srcItem)
+		}
+	}
+	return merged
+}
+
+func listMergeKey(item interface{}, key string) (string, bool) {
+	m, ok := item.(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	value, ok := m[key]
+	if !ok {
+		return "", false
+	}
+	return fmt.Sprint(value), true
+}
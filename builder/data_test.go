@@ -0,0 +1,153 @@
+package builder
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func getMergeExampleArgs(strategy ListMergeStrategy) *Args {
+	return &Args{
+		DataFiles: []string{GetExample("data-merge/base.yml"), GetExample("data-merge/prod.json")},
+		ListMerge: strategy,
+	}
+}
+
+func TestReadDataMerge(t *testing.T) {
+	servers := map[string]interface{}{
+		"webApp": map[string]interface{}{"name": "test.local", "port": float64(443)},
+		"api":    map[string]interface{}{"port": 8080},
+	}
+	tests := []struct {
+		name      string
+		strategy  ListMergeStrategy
+		wantUsers []interface{}
+		wantErr   bool
+	}{
+		{"replace lists", "", []interface{}{
+			map[string]interface{}{"name": "bob", "role": "admin"},
+			map[string]interface{}{"name": "carol"},
+		}, false},
+		{"append lists", ListMergeAppend, []interface{}{
+			map[string]interface{}{"name": "alice", "role": "admin"},
+			map[string]interface{}{"name": "bob", "role": "dev"},
+			map[string]interface{}{"name": "bob", "role": "admin"},
+			map[string]interface{}{"name": "carol"},
+		}, false},
+		{"merge lists by key", ListMergeByKey, []interface{}{
+			map[string]interface{}{"name": "alice", "role": "admin"},
+			map[string]interface{}{"name": "bob", "role": "admin"},
+			map[string]interface{}{"name": "carol"},
+		}, false},
+		{"unknown strategy", "shuffle", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := readData(getMergeExampleArgs(tt.strategy))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readData() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := data.(map[string]interface{})["servers"]; !reflect.DeepEqual(got, servers) {
+				t.Errorf("readData() servers = %v, want %v", got, servers)
+			}
+			if got := data.(map[string]interface{})["users"]; !reflect.DeepEqual(got, tt.wantUsers) {
+				t.Errorf("readData() users = %v, want %v", got, tt.wantUsers)
+			}
+		})
+	}
+}
+
+func TestReadDataJSONFromYAML(t *testing.T) {
+	data, err := readData(getMergeExampleArgs(""))
+	panicOnErr(err)
+	result, err := process("", `{{ json .servers.api }}`, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(result) != `{"port":8080}` {
+		t.Errorf("json of yaml data = %s", result)
+	}
+}
+
+func TestParseOverrides(t *testing.T) {
+	certFile := filepath.Join(getTestFolder(t, map[string]string{"cert.pem": "-----BEGIN-----\n"}), "cert.pem")
+	tests := []struct {
+		name     string
+		parse    func(string) (DataOverride, error)
+		override string
+		want     DataOverride
+		wantErr  bool
+	}{
+		{"int", ParseSetOverride, "servers.webApp.port=8080", DataOverride{Path: "servers.webApp.port", Value: 8080}, false},
+		{"bool", ParseSetOverride, "servers.webApp.tls=true", DataOverride{Path: "servers.webApp.tls", Value: true}, false},
+		{"negative int", ParseSetOverride, "a=-1", DataOverride{Path: "a", Value: -1}, false},
+		{"float", ParseSetOverride, "a=1.5", DataOverride{Path: "a", Value: 1.5}, false},
+		{"octal stays a string", ParseSetOverride, "m=0644", DataOverride{Path: "m", Value: "0644"}, false},
+		{"version stays a string", ParseSetOverride, "v=1.10", DataOverride{Path: "v", Value: "1.10"}, false},
+		{"exponent stays a string", ParseSetOverride, "v=1e5", DataOverride{Path: "v", Value: "1e5"}, false},
+		{"yaml 1.1 boolean stays a string", ParseSetOverride, "y=no", DataOverride{Path: "y", Value: "no"}, false},
+		{"capitalized boolean stays a string", ParseSetOverride, "y=True", DataOverride{Path: "y", Value: "True"}, false},
+		{"not a number stays a string", ParseSetOverride, "y=NaN", DataOverride{Path: "y", Value: "NaN"}, false},
+		{"empty value", ParseSetOverride, "y=", DataOverride{Path: "y", Value: ""}, false},
+		{"equal sign in value", ParseSetOverride, "servers.webApp.name=a=b", DataOverride{Path: "servers.webApp.name", Value: "a=b"}, false},
+		{"no value", ParseSetOverride, "no-value", DataOverride{}, true},
+		{"json", ParseSetJSONOverride, `servers.db={"hosts":["a","b"]}`, DataOverride{Path: "servers.db", Value: map[string]interface{}{"hosts": []interface{}{"a", "b"}}}, false},
+		{"json number", ParseSetJSONOverride, "servers.db.port=5432", DataOverride{Path: "servers.db.port", Value: float64(5432)}, false},
+		{"invalid json", ParseSetJSONOverride, "a={", DataOverride{}, true},
+		{"file", ParseSetFileOverride, "servers.webApp.cert=" + certFile, DataOverride{Path: "servers.webApp.cert", Value: "-----BEGIN-----\n"}, false},
+		{"missing file", ParseSetFileOverride, "a=" + certFile + ".missing", DataOverride{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.parse(tt.override)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parse(%q) error = %v, wantErr %v", tt.override, err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parse(%q) = %v, want %v", tt.override, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadDataOverrides(t *testing.T) {
+	args := getMergeExampleArgs("")
+	args.Overrides = []DataOverride{
+		{Path: "servers.webApp.port", Value: 8080},
+		{Path: "servers.db", Value: map[string]interface{}{"hosts": []interface{}{"a", "b"}}},
+		{Path: "servers.db.port", Value: float64(5432)},
+		{Path: "users.0.role", Value: "guest"},
+	}
+	t.Setenv("TEST_BOB_DATA_SERVERS__API__PORT", "9090")
+	t.Setenv("TEST_BOB_DATA_new__Key", "value")
+	args.EnvPrefix = "TEST_BOB_DATA_"
+
+	data, err := readData(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"servers": map[string]interface{}{
+			"webApp": map[string]interface{}{"name": "test.local", "port": 8080},
+			"api":    map[string]interface{}{"port": 9090},
+			"db":     map[string]interface{}{"hosts": []interface{}{"a", "b"}, "port": float64(5432)},
+		},
+		"users": []interface{}{
+			map[string]interface{}{"name": "bob", "role": "guest"},
+			map[string]interface{}{"name": "carol"},
+		},
+		"new": map[string]interface{}{"Key": "value"},
+	}
+	if !reflect.DeepEqual(data, want) {
+		t.Errorf("readData() = %v, want %v", data, want)
+	}
+
+	args.Overrides = []DataOverride{{Path: "a..b", Value: 1}}
+	if _, err = readData(args); err == nil {
+		t.Error("readData() expected an error for an empty key")
+	}
+}
@@ -0,0 +1,187 @@
+package builder
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"github.com/hashicorp/hcl"
+	"gopkg.in/yaml.v2"
+)
+
+// DataReader parses the contents of a data file
+type DataReader func(dataBytes []byte) (data map[string]interface{}, err error)
+
+var (
+	dataReadersLock = &sync.RWMutex{}
+	dataReaders     = map[string]DataReader{
+		".json": readJSON,
+		".yml":  readYAML,
+		".yaml": readYAML,
+		".toml": readTOML,
+		".hcl":  readHCL,
+		".env":  readDotenv,
+		".ini":  readINI,
+	}
+)
+
+// RegisterDataReader registers a reader for data files with the given extension like ".xml", existing readers for
+// the same extension are replaced
+func RegisterDataReader(extension string, reader DataReader) {
+	dataReadersLock.Lock()
+	defer dataReadersLock.Unlock()
+	dataReaders[strings.ToLower(extension)] = reader
+}
+
+// DataFileExtensions lists all extensions, that there is a reader for
+func DataFileExtensions() (extensions []string) {
+	dataReadersLock.RLock()
+	defer dataReadersLock.RUnlock()
+	for extension := range dataReaders {
+		extensions = append(extensions, extension)
+	}
+	sort.Strings(extensions)
+	return extensions
+}
+
+func getDataReader(filename string) (reader DataReader, ok bool) {
+	dataReadersLock.RLock()
+	defer dataReadersLock.RUnlock()
+	filename = strings.ToLower(filename)
+	extension := ""
+	for e, r := range dataReaders {
+		// the longest matching extension wins, which allows .tar.gz style extensions
+		if strings.HasSuffix(filename, e) && len(e) > len(extension) {
+			extension, reader = e, r
+		}
+	}
+	return reader, reader != nil
+}
+
+func readJSON(dataBytes []byte) (data map[string]interface{}, err error) {
+	err = json.Unmarshal(dataBytes, &data)
+	return data, err
+}
+
+func readYAML(dataBytes []byte) (data map[string]interface{}, err error) {
+	err = yaml.Unmarshal(dataBytes, &data)
+	return data, err
+}
+
+func readTOML(dataBytes []byte) (data map[string]interface{}, err error) {
+	err = toml.Unmarshal(dataBytes, &data)
+	return data, err
+}
+
+// readHCL flattens the lists of objects, that hcl makes of blocks, so that `listener "tcp" { address = "..." }`
+// is available as .listener.tcp.address
+func readHCL(dataBytes []byte) (data map[string]interface{}, err error) {
+	err = hcl.Unmarshal(dataBytes, &data)
+	if err != nil {
+		return nil, err
+	}
+	return flattenHCLBlocks(data).(map[string]interface{}), nil
+}
+
+func flattenHCLBlocks(value interface{}) interface{} {
+	switch v := value.(type) {
+	case []map[string]interface{}:
+		m := map[string]interface{}{}
+		for _, block := range v {
+			for key, value := range block {
+				m[key] = mergeData(m[key], flattenHCLBlocks(value), ListMergeReplace, "")
+			}
+		}
+		return m
+	case map[string]interface{}:
+		for key, value := range v {
+			v[key] = flattenHCLBlocks(value)
+		}
+		return v
+	case []interface{}:
+		for i, value := range v {
+			v[i] = flattenHCLBlocks(value)
+		}
+		return v
+	}
+	return value
+}
+
+// readDotenv reads KEY=value lines, values stay strings
+func readDotenv(dataBytes []byte) (data map[string]interface{}, err error) {
+	data = map[string]interface{}{}
+	scanner := bufio.NewScanner(bytes.NewReader(dataBytes))
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("line %d: expected KEY=value", lineNumber)
+		}
+		value, err := unquote(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %q", lineNumber, err)
+		}
+		data[strings.TrimSpace(parts[0])] = value
+	}
+	return data, scanner.Err()
+}
+
+// readINI reads key = value lines into maps for their [section], values stay strings
+func readINI(dataBytes []byte) (data map[string]interface{}, err error) {
+	data = map[string]interface{}{}
+	section := data
+	scanner := bufio.NewScanner(bytes.NewReader(dataBytes))
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			existing, ok := data[name].(map[string]interface{})
+			if !ok {
+				existing = map[string]interface{}{}
+				data[name] = existing
+			}
+			section = existing
+			continue
+		}
+		separator := strings.IndexAny(line, "=:")
+		if separator < 1 {
+			return nil, fmt.Errorf("line %d: expected key = value", lineNumber)
+		}
+		value, err := unquote(strings.TrimSpace(line[separator+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %q", lineNumber, err)
+		}
+		section[strings.TrimSpace(line[:separator])] = value
+	}
+	return data, scanner.Err()
+}
+
+func unquote(value string) (string, error) {
+	if len(value) < 2 {
+		return value, nil
+	}
+	switch {
+	case value[0] == '"' && value[len(value)-1] == '"':
+		return strconv.Unquote(value)
+	case value[0] == '\'' && value[len(value)-1] == '\'':
+		return value[1 : len(value)-1], nil
+	}
+	return value, nil
+}
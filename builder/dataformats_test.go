@@ -0,0 +1,88 @@
+package builder
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDataReaders(t *testing.T) {
+	tests := []struct {
+		file string
+		want map[string]interface{}
+	}{
+		{"app.toml", map[string]interface{}{
+			"name":   "toml",
+			"server": map[string]interface{}{"port": int64(80)},
+			"users": []interface{}{
+				map[string]interface{}{"name": "alice"},
+				map[string]interface{}{"name": "bob"},
+			},
+		}},
+		{"vault.hcl", map[string]interface{}{
+			"backend":  map[string]interface{}{"file": map[string]interface{}{"path": "db"}},
+			"listener": map[string]interface{}{"tcp": map[string]interface{}{"address": "127.0.0.1:8200", "tls_disable": 1}},
+		}},
+		{".env", map[string]interface{}{
+			"DB_HOST":     "localhost",
+			"DB_PASSWORD": "se\"cret",
+			"DB_USER":     "user",
+		}},
+		{"legacy.ini", map[string]interface{}{
+			"global":   "yes",
+			"database": map[string]interface{}{"host": "db.local", "port": "5432"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			data, err := readData(&Args{DataFiles: []string{GetExample(filepath.Join("data-formats", tt.file))}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(data, tt.want) {
+				t.Errorf("readData() = %v, want %v", data, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegisterDataReader(t *testing.T) {
+	dataFolder := getTestFolder(t, map[string]string{"data.custom": "whatever", "data.unknown": ""})
+	builderArgs := []string{filepath.Join(dataFolder, "data.custom"), dataFolder, dataFolder}
+	if _, err := GetBuilderArgs(builderArgs); err == nil {
+		t.Error("GetBuilderArgs() expected an error for an unknown data file extension")
+	}
+
+	defer func() {
+		dataReadersLock.Lock()
+		defer dataReadersLock.Unlock()
+		delete(dataReaders, ".custom")
+	}()
+	RegisterDataReader(".custom", func(dataBytes []byte) (map[string]interface{}, error) {
+		if len(dataBytes) == 0 {
+			return nil, errors.New("empty")
+		}
+		return map[string]interface{}{"custom": string(dataBytes)}, nil
+	})
+	args, err := GetBuilderArgs(builderArgs)
+	panicOnErr(err)
+	data, err := readData(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]interface{}{"custom": "whatever"}; !reflect.DeepEqual(data, want) {
+		t.Errorf("readData() = %v, want %v", data, want)
+	}
+	found := false
+	for _, extension := range DataFileExtensions() {
+		found = found || extension == ".custom"
+	}
+	if !found {
+		t.Errorf("DataFileExtensions() = %v, want .custom", DataFileExtensions())
+	}
+
+	if _, err = readData(&Args{DataFiles: []string{filepath.Join(dataFolder, "data.unknown")}}); err == nil {
+		t.Error("readData() expected an error for an unknown data file extension")
+	}
+}
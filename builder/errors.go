@@ -0,0 +1,128 @@
+package builder
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TemplateError is an error in a file, Line and Column are 0, when they are unknown. Errors in partials are reported
+// where the file includes them.
+type TemplateError struct {
+	File   string
+	Line   int
+	Column int
+	// Partials are the locations in the included partials like _partials/p:2:8, the innermost one comes last
+	Partials []string
+	Message  string
+	Err      error
+}
+
+func (e *TemplateError) Error() string {
+	location := formatLocation(e.File, e.Line, e.Column)
+	for _, partial := range e.Partials {
+		location += ": " + partial
+	}
+	return location + ": " + e.Message
+}
+
+func formatLocation(file string, line, column int) string {
+	if line > 0 {
+		file += ":" + strconv.Itoa(line)
+	}
+	if column > 0 {
+		file += ":" + strconv.Itoa(column)
+	}
+	return file
+}
+
+func (e *TemplateError) Unwrap() error {
+	return e.Err
+}
+
+// templateErrorRegexp matches the locations text/template puts into parse errors (name:line) and execution errors
+// (name:line:col)
+var templateErrorRegexp = regexp.MustCompile(`^template: (.+?):(\d+)(?::(\d+))?: ((?s).*)$`)
+
+// newTemplateError takes the position from errors of text/template. Errors of included templates are nested, the
+// outermost one has the position in the file and the inner ones are the positions in the partials.
+func newTemplateError(file string, err error) *TemplateError {
+	if templateError, ok := err.(*TemplateError); ok {
+		return templateError
+	}
+	templateError := &TemplateError{
+		File:    file,
+		Message: err.Error(),
+		Err:     err,
+	}
+	message := err.Error()
+	if i := strings.Index(message, "template: "); i > 0 {
+		message = message[i:]
+	}
+	for outermost := true; strings.HasPrefix(message, "template: "); outermost = false {
+		match := templateErrorRegexp.FindStringSubmatch(message)
+		if match == nil {
+			break
+		}
+		line, _ := strconv.Atoi(match[2])
+		column, _ := strconv.Atoi(match[3])
+		if outermost {
+			templateError.File, templateError.Line, templateError.Column = match[1], line, column
+		} else {
+			templateError.Partials = append(templateError.Partials, formatLocation(path.Join(partialsFolder, match[1]), line, column))
+		}
+		templateError.Message = match[4]
+		message = match[4]
+		if i := strings.Index(message, "template: "); i > 0 {
+			message = message[i:]
+		}
+	}
+	return templateError
+}
+
+// shift moves errors in file by lines, for templates, that do not start at the top of file
+func (e *TemplateError) shift(file string, lines int) *TemplateError {
+	if e.File == file && e.Line > 0 {
+		e.Line += lines
+	}
+	return e
+}
+
+// Errors are all errors of a build
+type Errors []error
+
+func (errs Errors) Error() string {
+	if len(errs) == 1 {
+		return errs[0].Error()
+	}
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+	return fmt.Sprintf("%d errors:\n%s", len(errs), strings.Join(messages, "\n"))
+}
+
+// add adds an error, errors are flattened
+func (errs Errors) add(err error) Errors {
+	if err == nil {
+		return errs
+	}
+	if others, ok := err.(Errors); ok {
+		return append(errs, others...)
+	}
+	return append(errs, err)
+}
+
+// err returns nil, if there are no errors
+func (errs Errors) err() error {
+	if len(errs) == 0 {
+		return nil
+	}
+	sort.SliceStable(errs, func(i, j int) bool {
+		return errs[i].Error() < errs[j].Error()
+	})
+	return errs
+}
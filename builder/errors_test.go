@@ -0,0 +1,92 @@
+package builder
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestBuildCollectsErrors(t *testing.T) {
+	sourceA := getTestFolder(t, map[string]string{
+		"ok.conf":        "{{ .name }}",
+		"parse.conf":     "line\n{{ if .name }}",
+		"exec.conf":      "line\nline\n  {{ .missing }}",
+		"partial.conf":   `{{ include "p" . }}`,
+		"include.conf":   "line\n{{ if true }}{{ include \"p\" . }}{{ end }}",
+		"_partials/p":    "\n{{ .deep.missing }}",
+		"frontmatter.md": "---\nmode: rwx\n---\n",
+		"delims.conf":    "---\ndelims: ['[[', ']]']\n---\nline\n  [[ .missing ]]",
+		"parse.md":       "---\nmode: '0600'\n---\n{{ end }}",
+	})
+	sourceB := getTestFolder(t, map[string]string{
+		"func.conf": `{{ join .name "," }}`,
+	})
+	_, err := Build(&Args{
+		SourceFolders: []string{sourceA, sourceB},
+		Overrides:     []DataOverride{{Path: "name", Value: "bob"}},
+	})
+	var errs Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Build() error = %v, want Errors", err)
+	}
+	var locations []string
+	for _, err := range errs {
+		var templateError *TemplateError
+		if !errors.As(err, &templateError) {
+			t.Errorf("Build() error %v is not a TemplateError", err)
+			continue
+		}
+		templateError.Message = ""
+		locations = append(locations, templateError.Error())
+	}
+	sort.Strings(locations)
+	want := []string{
+		filepath.Join(sourceA, "exec.conf") + ":3:5: ",
+		filepath.Join(sourceA, "delims.conf") + ":5:5: ",
+		filepath.Join(sourceA, "parse.md") + ":4: ",
+		filepath.Join(sourceA, "frontmatter.md") + ": ",
+		filepath.Join(sourceA, "parse.conf") + ":2: ",
+		filepath.Join(sourceB, "func.conf") + ":1:3: ",
+		filepath.Join(sourceA, "partial.conf") + ":1:3: _partials/p:2:8: ",
+		filepath.Join(sourceA, "include.conf") + ":2:16: _partials/p:2:8: ",
+	}
+	sort.Strings(want)
+	if !reflect.DeepEqual(locations, want) {
+		t.Errorf("Build() error locations = %q, want %q", locations, want)
+	}
+	if !strings.HasPrefix(err.Error(), "8 errors:\n") {
+		t.Errorf("Build() error = %q", err.Error())
+	}
+}
+
+func TestNewTemplateError(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		want    TemplateError
+		wantMsg string
+	}{
+		{"no template error", errors.New("no template"), TemplateError{File: "file", Message: "no template"}, "file: no template"},
+		{"parse error", errors.New("template: file:3: unexpected EOF"), TemplateError{File: "file", Line: 3, Message: "unexpected EOF"}, "file:3: unexpected EOF"},
+		{"exec error", errors.New(`template: file:3:5: executing "file" at <.a>: map has no entry for key "a"`), TemplateError{File: "file", Line: 3, Column: 5, Message: `executing "file" at <.a>: map has no entry for key "a"`}, `file:3:5: executing "file" at <.a>: map has no entry for key "a"`},
+		{"partial error", errors.New(`template: file:1:3: executing "file" at <include "p" .>: error calling include: template: p:2:8: executing "p" at <include "q/r" .>: error calling include: template: q/r:1:2: executing "q/r" at <.a>: map has no entry for key "a"`), TemplateError{File: "file", Line: 1, Column: 3, Partials: []string{"_partials/p:2:8", "_partials/q/r:1:2"}, Message: `executing "q/r" at <.a>: map has no entry for key "a"`}, `file:1:3: _partials/p:2:8: _partials/q/r:1:2: executing "q/r" at <.a>: map has no entry for key "a"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newTemplateError("file", tt.err)
+			if got.Err != tt.err {
+				t.Errorf("newTemplateError() Err = %v, want %v", got.Err, tt.err)
+			}
+			tt.want.Err = tt.err
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("newTemplateError() = %v, want %v", *got, tt.want)
+			}
+			if got.Error() != tt.wantMsg {
+				t.Errorf("newTemplateError() Error() = %q, want %q", got.Error(), tt.wantMsg)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package builder
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// folderConfigName is the name of the yaml file, that configures how the files of its folder and all sub folders
+// are rendered
+const folderConfigName = ".bobconfig"
+
+type folderConfig struct {
+	Delims []string `yaml:"delims"`
+	// Schemas maps globs for output paths to json schema files relative to the folder of the config
+	Schemas map[string]string `yaml:"schemas"`
+}
+
+// folderConfigs loads .bobconfig files below a source folder, where a config in a sub folder overrides the settings
+// of its parent folders
+type folderConfigs struct {
+	root    string
+	configs map[string]*folderConfig
+}
+
+func newFolderConfigs(root string) *folderConfigs {
+	return &folderConfigs{
+		root:    root,
+		configs: map[string]*folderConfig{},
+	}
+}
+
+// get returns the config for a folder relative to the root
+func (c *folderConfigs) get(folder string) (config *folderConfig, err error) {
+	folder = path.Clean(folder)
+	if config, ok := c.configs[folder]; ok {
+		return config, nil
+	}
+	config = &folderConfig{}
+	if folder != "." {
+		parentConfig, err := c.get(path.Dir(folder))
+		if err != nil {
+			return nil, err
+		}
+		*config = *parentConfig
+		config.Schemas = map[string]string{}
+		for glob, schema := range parentConfig.Schemas {
+			config.Schemas[glob] = schema
+		}
+	}
+	configFile := path.Join(c.root, folder, folderConfigName)
+	configBytes, err := ioutil.ReadFile(configFile)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		fileConfig := &folderConfig{}
+		err = yaml.UnmarshalStrict(configBytes, fileConfig)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %q: %q", configFile, err)
+		}
+		if len(fileConfig.Delims) != 0 && len(fileConfig.Delims) != 2 {
+			return nil, fmt.Errorf("invalid %q: delims needs a left and a right delimiter, got %q", configFile, fileConfig.Delims)
+		}
+		if len(fileConfig.Delims) == 2 {
+			config.Delims = fileConfig.Delims
+		}
+		for glob, schema := range fileConfig.Schemas {
+			if _, err := path.Match(glob, ""); err != nil {
+				return nil, fmt.Errorf("invalid %q: schema glob %q: %q", configFile, glob, err)
+			}
+			if !path.IsAbs(schema) {
+				schema = path.Join(c.root, folder, schema)
+			}
+			if config.Schemas == nil {
+				config.Schemas = map[string]string{}
+			}
+			config.Schemas[glob] = schema
+		}
+	}
+	c.configs[folder] = config
+	return config, nil
+}
+
+// renderer returns a renderer with the settings of the config
+func (config *folderConfig) renderer(r *renderer) *renderer {
+	if len(config.Delims) == 2 {
+		return r.withDelims(config.Delims[0], config.Delims[1])
+	}
+	return r
+}
+
+// getSchemas returns the schema files for an output path, globs without a slash match the name of the file
+func (config *folderConfig) getSchemas(outputPath string) (schemas []string) {
+	for glob, schema := range config.Schemas {
+		name := outputPath
+		if !strings.Contains(glob, "/") {
+			name = path.Base(outputPath)
+		}
+		if matched, _ := path.Match(glob, name); matched {
+			schemas = append(schemas, schema)
+		}
+	}
+	sort.Strings(schemas)
+	return schemas
+}
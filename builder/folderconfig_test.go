@@ -0,0 +1,66 @@
+package builder
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFolderConfigDelims(t *testing.T) {
+	result, err := Build(&Args{
+		SourceFolders: []string{GetExample("source-delims")},
+		Overrides:     []DataOverride{{Path: "name", Value: "bob"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"default.conf":                "bob\n",
+		"nginx/site.conf":             "bob {{ $host }}\n",
+		"nginx/sub/deep.conf":         "bob\n",
+		"nginx/sub/front-matter.conf": "bob [[ .name ]]\n",
+		"nginx/jinja/app.j2":          "bob {% if x %}\n",
+	}
+	if got := getContents(result); !reflect.DeepEqual(got, want) {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+}
+
+func TestFolderConfigPartialDelims(t *testing.T) {
+	source := getTestFolder(t, map[string]string{
+		".bobconfig":                 `delims: ["[[", "]]"]`,
+		"_partials/p":                "name=[[ .name ]]",
+		"_partials/curly/.bobconfig": `delims: ["{{", "}}"]`,
+		"_partials/curly/p":          "name={{ .name }}",
+		"a.conf":                     `[[ include "p" . ]] [[ include "curly/p" . ]]`,
+	})
+	result, err := Build(&Args{
+		SourceFolders: []string{source},
+		Overrides:     []DataOverride{{Path: "name", Value: "bob"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"a.conf": "name=bob name=bob"}
+	if got := getContents(result); !reflect.DeepEqual(got, want) {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+}
+
+func TestFolderConfigErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		bobconfig string
+	}{
+		{"unknown key", "delimiters: ['[[', ']]']"},
+		{"one delimiter", "delims: ['[[']"},
+		{"no yaml", "delims: ["},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := getTestFolder(t, map[string]string{".bobconfig": tt.bobconfig, "a.txt": ""})
+			if _, err := Build(&Args{SourceFolders: []string{source}}); err == nil {
+				t.Error("Build() expected an error")
+			}
+		})
+	}
+}
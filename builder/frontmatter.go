@@ -0,0 +1,130 @@
+package builder
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+
+	"gopkg.in/yaml.v2"
+)
+
+const frontMatterSeparator = "---"
+
+// frontMatter is an optional yaml block at the top of a template, that controls its output file
+type frontMatter struct {
+	// Mode in octal like "0600"
+	Mode  string `yaml:"mode"`
+	Owner string `yaml:"owner"`
+	Group string `yaml:"group"`
+	// Path renames the output file, it is rendered like the template and relative to the folder of the template
+	Path   string   `yaml:"path"`
+	Delims []string `yaml:"delims"`
+	// Secret files are written with mode 0600 by default and their contents are not shown in plans
+	Secret bool `yaml:"secret"`
+	// lines of the front matter, the lines of the template are reported where they are in the file
+	lines int
+}
+
+var frontMatterKeys = map[string]bool{
+	"mode":   true,
+	"owner":  true,
+	"group":  true,
+	"path":   true,
+	"delims": true,
+	"secret": true,
+}
+
+// splitFrontMatter separates the front matter from the template. A leading yaml document only counts as front matter,
+// if all its keys are front matter keys, so that templates of multi document yaml files keep working.
+func splitFrontMatter(contents []byte) (fm *frontMatter, template []byte, err error) {
+	fm = &frontMatter{}
+	firstLine, rest := cutLine(contents)
+	if string(bytes.TrimRight(firstLine, "\r")) != frontMatterSeparator {
+		return fm, contents, nil
+	}
+	var block []byte
+	for len(rest) > 0 {
+		var l []byte
+		l, rest = cutLine(rest)
+		if string(bytes.TrimRight(l, "\r")) == frontMatterSeparator {
+			keys := map[string]interface{}{}
+			if yaml.Unmarshal(block, &keys) != nil || len(keys) == 0 {
+				return fm, contents, nil
+			}
+			for key := range keys {
+				if !frontMatterKeys[key] {
+					return fm, contents, nil
+				}
+			}
+			err = yaml.UnmarshalStrict(block, fm)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid front matter: %q", err)
+			}
+			if len(fm.Delims) != 0 && len(fm.Delims) != 2 {
+				return nil, nil, fmt.Errorf("invalid front matter: delims needs a left and a right delimiter, got %q", fm.Delims)
+			}
+			fm.lines = bytes.Count(contents[:len(contents)-len(rest)], []byte("\n"))
+			return fm, rest, nil
+		}
+		block = append(block, l...)
+		block = append(block, '\n')
+	}
+	return fm, contents, nil
+}
+
+func cutLine(b []byte) (l, rest []byte) {
+	i := bytes.IndexByte(b, '\n')
+	if i < 0 {
+		return b, nil
+	}
+	return b[:i], b[i+1:]
+}
+
+// getMode returns the mode from the front matter or the default
+func (fm *frontMatter) getMode(defaultMode os.FileMode) (os.FileMode, error) {
+	if fm.Mode == "" {
+		if fm.Secret {
+			return 0600, nil
+		}
+		return defaultMode, nil
+	}
+	mode, err := strconv.ParseUint(fm.Mode, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("invalid front matter: mode %q is not an octal permission like 0644", fm.Mode)
+	}
+	return os.FileMode(mode), nil
+}
+
+// getOwnership looks up user and group names or ids, -1 keeps them as they are
+func getOwnership(owner, group string) (uid, gid int, err error) {
+	uid, gid = -1, -1
+	if owner != "" {
+		u, err := user.Lookup(owner)
+		if err != nil {
+			u, err = user.LookupId(owner)
+		}
+		if err != nil {
+			return 0, 0, fmt.Errorf("unknown owner %q", owner)
+		}
+		uid, err = strconv.Atoi(u.Uid)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	if group != "" {
+		g, err := user.LookupGroup(group)
+		if err != nil {
+			g, err = user.LookupGroupId(group)
+		}
+		if err != nil {
+			return 0, 0, fmt.Errorf("unknown group %q", group)
+		}
+		gid, err = strconv.Atoi(g.Gid)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	return uid, gid, nil
+}
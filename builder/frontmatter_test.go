@@ -0,0 +1,108 @@
+package builder
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestSplitFrontMatter(t *testing.T) {
+	tests := []struct {
+		name         string
+		contents     string
+		want         *frontMatter
+		wantTemplate string
+		wantErr      bool
+	}{
+		{"front matter", "---\nmode: 0600\ndelims: ['[[', ']]']\n---\nkey: [[ .key ]]\n", &frontMatter{Mode: "0600", Delims: []string{"[[", "]]"}, lines: 4}, "key: [[ .key ]]\n", false},
+		{"no front matter", "no front matter", &frontMatter{}, "no front matter", false},
+		{"yaml document", "---\napiVersion: v1\nkind: Service\n---\napiVersion: v1\n", &frontMatter{}, "---\napiVersion: v1\nkind: Service\n---\napiVersion: v1\n", false},
+		{"not closed", "---\nmode: 0600\n", &frontMatter{}, "---\nmode: 0600\n", false},
+		{"unknown key", "---\npath: a\nname: b\n---\n", &frontMatter{}, "---\npath: a\nname: b\n---\n", false},
+		{"invalid value", "---\nsecret: maybe\n---\n", nil, "", true},
+		{"one delimiter", "---\ndelims: ['[[']\n---\n", nil, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fm, template, err := splitFrontMatter([]byte(tt.contents))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("splitFrontMatter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(fm, tt.want) {
+				t.Errorf("splitFrontMatter() front matter = %v, want %v", fm, tt.want)
+			}
+			if string(template) != tt.wantTemplate {
+				t.Errorf("splitFrontMatter() template = %q, want %q", template, tt.wantTemplate)
+			}
+		})
+	}
+}
+
+func TestFrontMatter(t *testing.T) {
+	source := getTestFolder(t, map[string]string{
+		"id_rsa.tmpl":        "---\nsecret: true\npath: id_rsa\n---\n{{ .key }}\n",
+		"public.conf":        "---\nmode: '0640'\n---\npublic\n",
+		"nginx/site.conf":    "---\ndelims: ['[[', ']]']\npath: '../[[ .name ]].conf'\n---\nlocation { return {{ .key }} [[ .name ]]; }\n",
+		"owned.conf":         "---\nowner: '" + strconv.Itoa(os.Getuid()) + "'\ngroup: '" + strconv.Itoa(os.Getgid()) + "'\n---\nowned\n",
+		"conflict/name.conf": "---\npath: ../public.conf\n---\n",
+	})
+	args := &Args{
+		SourceFolders: []string{source},
+		Overrides: []DataOverride{
+			{Path: "key", Value: "private"},
+			{Path: "name", Value: "site"},
+		},
+	}
+	if _, err := Build(args); err == nil {
+		t.Error("Build() expected an error for a path, that is already taken")
+	}
+
+	panicOnErr(os.RemoveAll(filepath.Join(source, "conflict")))
+	result, err := Build(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		file       string
+		wantSource string
+		wantMode   os.FileMode
+		want       string
+	}{
+		{"id_rsa", "id_rsa.tmpl", 0600, "private\n"},
+		{"public.conf", "public.conf", 0640, "public\n"},
+		{"site.conf", "nginx/site.conf", 0644, "location { return {{ .key }} site; }\n"},
+		{"owned.conf", "owned.conf", 0644, "owned\n"},
+	}
+	for _, tt := range tests {
+		fr, ok := result.Files[tt.file]
+		if !ok {
+			t.Errorf("Build() is missing %q", tt.file)
+			continue
+		}
+		if fr.source != tt.wantSource || fr.mode.Perm() != tt.wantMode || string(fr.bytes) != tt.want {
+			t.Errorf("Build() %q = %q %v %q, want %q %v %q", tt.file, fr.source, fr.mode.Perm(), fr.bytes, tt.wantSource, tt.wantMode, tt.want)
+		}
+	}
+
+	target := getTestFolder(t, map[string]string{"id_rsa": "old key\n"})
+	plan, err := GetPlan(target, result)
+	panicOnErr(err)
+	for _, filePlan := range plan.Files {
+		if filePlan.Path == "id_rsa" && filePlan.Diff != "secret contents differ\n" {
+			t.Errorf("GetPlan() shows the diff of a secret file %q", filePlan.Diff)
+		}
+	}
+
+	panicOnErr(WriteProcessingResult(target, result))
+	info, err := os.Stat(filepath.Join(target, "id_rsa"))
+	panicOnErr(err)
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("WriteProcessingResult() mode of secret file = %v", info.Mode().Perm())
+	}
+
+	if _, _, err = getOwnership("no-such-user-hopefully", ""); err == nil {
+		t.Error("getOwnership() expected an error for an unknown user")
+	}
+}
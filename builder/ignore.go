@@ -0,0 +1,177 @@
+package builder
+
+import (
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// pattern a line of a .bobignore or .bobcopy file with the semantics of a .gitignore pattern
+type pattern struct {
+	line    string
+	negate  bool
+	dirOnly bool
+	regexp  *regexp.Regexp
+}
+
+// parsePattern returns nil for empty lines and comments
+func parsePattern(line string) *pattern {
+	p := &pattern{line: line}
+	line = strings.TrimRight(line, "\r")
+	if !strings.HasSuffix(line, "\\ ") {
+		line = strings.TrimRight(line, " ")
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil
+	}
+	if strings.HasPrefix(line, "!") {
+		p.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, "\\!") || strings.HasPrefix(line, "\\#") {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return nil
+	}
+	// patterns without a slash match on every level, all others are relative to their folder
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	expression := globToRegexp(line)
+	if !anchored {
+		expression = "(?:.*/)?" + expression
+	}
+	var err error
+	p.regexp, err = regexp.Compile("^" + expression + "$")
+	if err != nil {
+		// should not happen, globToRegexp quotes everything, that it does not understand
+		p.regexp = regexp.MustCompile("^" + regexp.QuoteMeta(line) + "$")
+	}
+	return p
+}
+
+func globToRegexp(glob string) string {
+	expression := &strings.Builder{}
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/") && (i == 0 || glob[i-1] == '/'):
+			// zero or more folders
+			expression.WriteString("(?:.*/)?")
+			i += 2
+		case glob[i:] == "**" && i > 0 && glob[i-1] == '/':
+			// everything inside
+			expression.WriteString(".*")
+			i++
+		case c == '*':
+			expression.WriteString("[^/]*")
+			for i+1 < len(glob) && glob[i+1] == '*' {
+				i++
+			}
+		case c == '?':
+			expression.WriteString("[^/]")
+		case c == '\\' && i+1 < len(glob):
+			i++
+			expression.WriteString(regexp.QuoteMeta(string(glob[i])))
+		case c == '[':
+			end := strings.Index(glob[i+1:], "]")
+			if end < 0 {
+				expression.WriteString(regexp.QuoteMeta(string(c)))
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			class = "[" + strings.Replace(class, "\\", "\\\\", -1) + "]"
+			if _, err := regexp.Compile(class); err != nil {
+				// invalid classes like [z-a] match themselves
+				expression.WriteString(regexp.QuoteMeta(string(c)))
+				continue
+			}
+			expression.WriteString(class)
+			i += end + 1
+		default:
+			expression.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return expression.String()
+}
+
+func (p *pattern) match(relativePath string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+	return p.regexp.MatchString(relativePath)
+}
+
+func parsePatterns(lines []string) (patterns []*pattern) {
+	for _, line := range lines {
+		if p := parsePattern(line); p != nil {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+// patternMatcher matches paths relative to a root folder against patterns from the root folder and optionally
+// from pattern files in its sub folders, which apply relative to their own folder
+type patternMatcher struct {
+	root       string
+	nestedName string
+	lock       sync.Mutex
+	patterns   map[string][]*pattern
+}
+
+func newPatternMatcher(root string, lines []string, nestedName string) *patternMatcher {
+	return &patternMatcher{
+		root:       root,
+		nestedName: nestedName,
+		patterns:   map[string][]*pattern{"": parsePatterns(lines)},
+	}
+}
+
+func (m *patternMatcher) getPatterns(folder string) []*pattern {
+	if m.nestedName == "" && folder != "" {
+		return nil
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	patterns, ok := m.patterns[folder]
+	if !ok {
+		patterns = parsePatterns(getStuff(path.Join(m.root, folder), m.nestedName))
+		m.patterns[folder] = patterns
+	}
+	return patterns
+}
+
+// matches returns true, if the given path or one of its parent folders matches, because nothing can be
+// re-included from a matching folder
+func (m *patternMatcher) matches(relativePath string, isDir bool) bool {
+	parts := strings.Split(relativePath, "/")
+	for i := 1; i <= len(parts); i++ {
+		if m.matchesPath(parts[:i], i < len(parts) || isDir) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesPath applies the patterns of all parent folders in order, so that the last matching pattern from the
+// deepest folder wins
+func (m *patternMatcher) matchesPath(parts []string, isDir bool) (matches bool) {
+	for i := 0; i < len(parts); i++ {
+		folder := strings.Join(parts[:i], "/")
+		relativePath := strings.Join(parts[i:], "/")
+		for _, p := range m.getPatterns(folder) {
+			if p.match(relativePath, isDir) {
+				matches = !p.negate
+			}
+		}
+	}
+	return matches
+}
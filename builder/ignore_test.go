@@ -0,0 +1,75 @@
+package builder
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPatternMatcher(t *testing.T) {
+	tests := []struct {
+		name    string
+		lines   []string
+		path    string
+		isDir   bool
+		matches bool
+	}{
+		{"exact", []string{"httpd/ignore-me.txt"}, "httpd/ignore-me.txt", false, true},
+		{"exact other", []string{"httpd/ignore-me.txt"}, "httpd/keep-me.txt", false, false},
+		{"anchored", []string{"/foo.txt"}, "sub/foo.txt", false, false},
+		{"name on any level", []string{"foo.txt"}, "sub/foo.txt", false, true},
+		{"wildcard", []string{"*.bak"}, "a/b/c.bak", false, true},
+		{"wildcard does not cross folders", []string{"a/*.bak"}, "a/b/c.bak", false, false},
+		{"double star prefix", []string{"**/*.png"}, "a/b/c.png", false, true},
+		{"double star prefix top level", []string{"**/*.png"}, "c.png", false, true},
+		{"double star middle", []string{"a/**/c.png"}, "a/c.png", false, true},
+		{"double star middle deep", []string{"a/**/c.png"}, "a/b/b/c.png", false, true},
+		{"double star suffix", []string{"a/**"}, "a/b/c.png", false, true},
+		{"question mark", []string{"file?.txt"}, "file1.txt", false, true},
+		{"character class", []string{"file[0-3].txt"}, "file4.txt", false, false},
+		{"negated character class", []string{"file[!0-3].txt"}, "file4.txt", false, true},
+		{"invalid character class", []string{"file[z-a].txt"}, "file[z-a].txt", false, true},
+		{"invalid character class is no class", []string{"file[z-a].txt"}, "filez.txt", false, false},
+		{"empty character class", []string{"file[].txt"}, "file[].txt", false, true},
+		{"negation", []string{"*.txt", "!keep-me.txt"}, "keep-me.txt", false, false},
+		{"negation order", []string{"!keep-me.txt", "*.txt"}, "keep-me.txt", false, true},
+		{"comment", []string{"#foo.txt"}, "#foo.txt", false, false},
+		{"escaped comment", []string{"\\#foo.txt"}, "#foo.txt", false, true},
+		{"directory only on a file", []string{"logs/"}, "logs", false, false},
+		{"directory only on a directory", []string{"logs/"}, "logs", true, true},
+		{"files in a matching directory", []string{"logs/"}, "logs/today.log", false, true},
+		{"no re-include from matching directory", []string{"logs/", "!logs/keep.log"}, "logs/keep.log", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newPatternMatcher("does-not-exist", tt.lines, "")
+			if got := m.matches(tt.path, tt.isDir); got != tt.matches {
+				t.Errorf("matches() %q on %q = %v, want %v", tt.lines, tt.path, got, tt.matches)
+			}
+		})
+	}
+}
+
+func TestNestedIgnore(t *testing.T) {
+	root := GetExample("source-nested-ignore")
+	files, err := getFiles(root, getIgnore(root))
+	panicOnErr(err)
+	want := []string{"a.txt", "other/local.txt", "sub/deep/local.txt", "sub/keep.bak"}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("getFiles() = %v, want %v", files, want)
+	}
+}
+
+func TestInvalidIgnorePattern(t *testing.T) {
+	source := getTestFolder(t, map[string]string{
+		".bobignore":    "file[z-a].txt\n",
+		"file[z-a].txt": "ignored",
+		"filez.txt":     "built",
+	})
+	result, err := Build(&Args{SourceFolders: []string{source}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"filez.txt": "built"}; !reflect.DeepEqual(getContents(result), want) {
+		t.Errorf("Build() = %v, want %v", getContents(result), want)
+	}
+}
@@ -0,0 +1,644 @@
+package builder
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// LintFinding is a problem, that lint found in a template, data file or pattern file. Line and Column are 0, when
+// they are unknown.
+type LintFinding struct {
+	File    string
+	Line    int
+	Column  int
+	Kind    string
+	Message string
+}
+
+// kinds of lint findings
+const (
+	LintParseError       = "parse-error"
+	LintMissingKey       = "missing-key"
+	LintUnusedKey        = "unused-key"
+	LintSecretSyntax     = "secret-syntax"
+	LintUnmatchedPattern = "unmatched-pattern"
+)
+
+func (f LintFinding) String() string {
+	location := f.File
+	if f.Line > 0 {
+		location += ":" + strconv.Itoa(f.Line)
+	}
+	if f.Column > 0 {
+		location += ":" + strconv.Itoa(f.Column)
+	}
+	return location + ": " + f.Kind + ": " + f.Message
+}
+
+// listElements is the path segment for the elements of lists and the values of maps, that are ranged over
+const listElements = "[]"
+
+// Lint checks the templates in the source folders statically against the data without executing them, so no secrets
+// are read. It reports parse errors, referenced keys, that are not in the data, keys in the data, that are never
+// referenced, secret keys, that no secret provider accepts and .bobignore and .bobcopy patterns, that match
+// nothing.
+func Lint(args *Args) (findings []LintFinding, err error) {
+	data, err := readData(args)
+	if err != nil {
+		return nil, err
+	}
+	l := &linter{data: data}
+	for _, sourceFolder := range args.SourceFolders {
+		err = l.lintFolder(path.Clean(sourceFolder))
+		if err != nil {
+			return nil, err
+		}
+	}
+	findings = append(l.findings, l.unusedKeys(args)...)
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return findings, nil
+}
+
+type linter struct {
+	data       interface{}
+	references []lintReference
+	findings   []LintFinding
+}
+
+type lintReference struct {
+	path []string
+	// uses means, that the whole value is used and not only looked at like by range
+	uses bool
+}
+
+// lintContext is what dot and variables refer to, a nil path is unknown
+type lintContext struct {
+	dot       []string
+	variables map[string][]string
+}
+
+func (c *lintContext) with(dot []string) *lintContext {
+	variables := map[string][]string{}
+	for name, p := range c.variables {
+		variables[name] = p
+	}
+	return &lintContext{dot: dot, variables: variables}
+}
+
+func (l *linter) add(file string, line, column int, kind, message string) {
+	l.findings = append(l.findings, LintFinding{File: file, Line: line, Column: column, Kind: kind, Message: message})
+}
+
+func (l *linter) lintFolder(folder string) error {
+	ignore := getIgnore(folder)
+	files, err := getFiles(folder, ignore)
+	if err != nil {
+		return err
+	}
+	copied := newPatternMatcher(folder, getCopy(folder), "")
+	configs := newFolderConfigs(folder)
+	for _, file := range files {
+		filename := path.Join(folder, file)
+		config, err := configs.get(path.Dir(file))
+		if err != nil {
+			l.add(filename, 0, 0, LintParseError, err.Error())
+			continue
+		}
+		context := &lintContext{dot: []string{}, variables: map[string][]string{"$": {}}}
+		if isTemplatedPath(file) {
+			l.lintPath(filename, file, context)
+		}
+		if copied.matches(file, false) {
+			continue
+		}
+		contents, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+		fm, templ, err := splitFrontMatter(contents)
+		if err != nil {
+			l.add(filename, 0, 0, LintParseError, err.Error())
+			continue
+		}
+		delims := config.Delims
+		if len(fm.Delims) == 2 {
+			delims = fm.Delims
+		}
+		if fm.Path != "" {
+			l.lintTemplate(filename+" front matter path", fm.Path, delims, 0, context)
+		}
+		l.lintTemplate(filename, string(templ), delims, fm.lines, context)
+	}
+	err = l.lintPartials(folder, configs)
+	if err != nil {
+		return err
+	}
+	return l.lintPatterns(folder)
+}
+
+// lintPartials checks partials without data references, because their data depends on how they are called
+func (l *linter) lintPartials(folder string, configs *folderConfigs) error {
+	partials := path.Join(folder, partialsFolder)
+	if info, err := os.Stat(partials); err != nil || !info.IsDir() {
+		return nil
+	}
+	files, err := getFiles(partials, getIgnore(partials))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		filename := path.Join(partials, file)
+		config, err := configs.get(path.Join(partialsFolder, path.Dir(file)))
+		if err != nil {
+			l.add(filename, 0, 0, LintParseError, err.Error())
+			continue
+		}
+		contents, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+		l.lintTemplate(filename, string(contents), config.Delims, 0, &lintContext{variables: map[string][]string{}})
+	}
+	return nil
+}
+
+// lintPath checks a templated path and binds the keys of fanout to the elements of their lists
+func (l *linter) lintPath(filename, p string, context *lintContext) {
+	t, err := newTemplate(filename).Funcs(template.FuncMap{"fanout": func(string, interface{}) string { return "" }}).Parse(p)
+	if err != nil {
+		l.add(filename, 0, 0, LintParseError, "could not parse path: "+err.Error())
+		return
+	}
+	walkCommands(t.Tree.Root, func(cmd *parse.CommandNode) {
+		if len(cmd.Args) != 3 || !isIdentifier(cmd.Args[0], "fanout") {
+			return
+		}
+		key, ok := cmd.Args[1].(*parse.StringNode)
+		if !ok {
+			return
+		}
+		if list := l.nodePath(cmd.Args[2], context); list != nil {
+			// fanout keys are set on the root of the data
+			context.variables["."+key.Text] = append(list, listElements)
+		}
+	})
+	l.walk(t.Tree, t.Tree.Root, context)
+}
+
+// lintTemplate checks a template, that starts after lineOffset lines of its file
+func (l *linter) lintTemplate(filename, templ string, delims []string, lineOffset int, context *lintContext) {
+	start := len(l.findings)
+	defer func() {
+		for i := start; i < len(l.findings); i++ {
+			if l.findings[i].File == filename && l.findings[i].Line > 0 {
+				l.findings[i].Line += lineOffset
+			}
+		}
+	}()
+	t := newTemplate(filename)
+	if len(delims) == 2 {
+		t = t.Delims(delims[0], delims[1])
+	}
+	t, err := t.Parse(templ)
+	if err != nil {
+		templateError := newTemplateError(filename, err)
+		l.add(templateError.File, templateError.Line, templateError.Column, LintParseError, templateError.Message)
+		return
+	}
+	for _, tmpl := range t.Templates() {
+		if tmpl.Tree == nil {
+			continue
+		}
+		if tmpl.Name() == filename {
+			l.walk(tmpl.Tree, tmpl.Tree.Root, context)
+		} else {
+			// defined templates can be called with anything
+			l.walk(tmpl.Tree, tmpl.Tree.Root, &lintContext{variables: map[string][]string{}})
+		}
+	}
+}
+
+func (l *linter) walk(tree *parse.Tree, node parse.Node, context *lintContext) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			l.walk(tree, child, context)
+		}
+	case *parse.ActionNode:
+		l.declare(context, n.Pipe, l.walkPipe(tree, n.Pipe, context, true))
+	case *parse.IfNode:
+		pipePath := l.walkPipe(tree, n.Pipe, context, true)
+		ifContext := context.with(context.dot)
+		l.declare(ifContext, n.Pipe, pipePath)
+		l.walk(tree, n.List, ifContext)
+		l.walk(tree, n.ElseList, context.with(context.dot))
+	case *parse.WithNode:
+		pipePath := l.walkPipe(tree, n.Pipe, context, false)
+		withContext := context.with(pipePath)
+		l.declare(withContext, n.Pipe, pipePath)
+		l.walk(tree, n.List, withContext)
+		l.walk(tree, n.ElseList, context.with(context.dot))
+	case *parse.RangeNode:
+		pipePath := l.walkPipe(tree, n.Pipe, context, false)
+		var element []string
+		if pipePath != nil {
+			element = append(append([]string{}, pipePath...), listElements)
+		}
+		rangeContext := context.with(element)
+		switch len(n.Pipe.Decl) {
+		case 1:
+			rangeContext.variables[n.Pipe.Decl[0].Ident[0]] = element
+		case 2:
+			rangeContext.variables[n.Pipe.Decl[0].Ident[0]] = nil
+			rangeContext.variables[n.Pipe.Decl[1].Ident[0]] = element
+		}
+		l.walk(tree, n.List, rangeContext)
+		l.walk(tree, n.ElseList, context.with(context.dot))
+	case *parse.TemplateNode:
+		l.walkPipe(tree, n.Pipe, context, true)
+	}
+}
+
+// declare sets the variables of a pipeline
+func (l *linter) declare(context *lintContext, pipe *parse.PipeNode, pipePath []string) {
+	for _, variable := range pipe.Decl {
+		context.variables[variable.Ident[0]] = pipePath
+	}
+}
+
+// walkPipe checks all references in a pipeline and returns the path of its value, if it is a single reference. The
+// pipelines of with and range do not use the value as a whole, that is up to their bodies.
+func (l *linter) walkPipe(tree *parse.Tree, pipe *parse.PipeNode, context *lintContext, usesValue bool) (pipePath []string) {
+	if pipe == nil {
+		return nil
+	}
+	if len(pipe.Cmds) == 1 && len(pipe.Cmds[0].Args) == 1 {
+		pipePath = l.nodePath(pipe.Cmds[0].Args[0], context)
+		if pipePath != nil {
+			l.reference(tree, pipe.Cmds[0].Args[0], pipePath, usesValue)
+			return pipePath
+		}
+	}
+	for _, cmd := range pipe.Cmds {
+		l.walkCommand(tree, cmd, context)
+	}
+	return nil
+}
+
+func (l *linter) walkCommand(tree *parse.Tree, cmd *parse.CommandNode, context *lintContext) {
+	if (isIdentifier(cmd.Args[0], "secret") || isIdentifier(cmd.Args[0], "secretMap")) && len(cmd.Args) == 2 {
+		validate := validateSecretKey
+		if isIdentifier(cmd.Args[0], "secretMap") {
+			validate = validateSecretMapKey
+		}
+		if key, ok := cmd.Args[1].(*parse.StringNode); ok {
+			if err := validate(key.Text); err != nil {
+				line, column := nodePosition(tree, key)
+				l.add(tree.ParseName, line, column, LintSecretSyntax, err.Error())
+			}
+		}
+	}
+	if isIdentifier(cmd.Args[0], "fanout") && len(cmd.Args) == 3 {
+		// like range, fanout only looks at the list
+		if p := l.nodePath(cmd.Args[2], context); p != nil {
+			l.reference(tree, cmd.Args[2], p, false)
+			return
+		}
+	}
+	if isIdentifier(cmd.Args[0], "index") && len(cmd.Args) > 2 {
+		if p := l.indexPath(cmd.Args[1:], context); p != nil {
+			l.reference(tree, cmd.Args[1], p, true)
+			return
+		}
+	}
+	for _, arg := range cmd.Args {
+		switch n := arg.(type) {
+		case *parse.PipeNode:
+			l.walkPipe(tree, n, context.with(context.dot), true)
+		case *parse.ChainNode:
+			if pipe, ok := n.Node.(*parse.PipeNode); ok {
+				l.walkPipe(tree, pipe, context.with(context.dot), true)
+			}
+		default:
+			if p := l.nodePath(n, context); p != nil {
+				l.reference(tree, n, p, true)
+			}
+		}
+	}
+}
+
+// indexPath is the path of index calls with constant keys
+func (l *linter) indexPath(args []parse.Node, context *lintContext) []string {
+	p := l.nodePath(args[0], context)
+	if p == nil {
+		return nil
+	}
+	for _, arg := range args[1:] {
+		switch key := arg.(type) {
+		case *parse.StringNode:
+			p = append(p, key.Text)
+		case *parse.NumberNode:
+			p = append(p, listElements)
+		default:
+			return nil
+		}
+	}
+	return p
+}
+
+// nodePath is the data path of a node or nil, if it is unknown
+func (l *linter) nodePath(node parse.Node, context *lintContext) []string {
+	var base, idents []string
+	switch n := node.(type) {
+	case *parse.DotNode:
+		base = context.dot
+	case *parse.FieldNode:
+		base, idents = context.dot, n.Ident
+		if bound, ok := context.variables["."+n.Ident[0]]; ok && len(context.dot) == 0 {
+			base, idents = bound, n.Ident[1:]
+		}
+	case *parse.VariableNode:
+		base, idents = context.variables[n.Ident[0]], n.Ident[1:]
+	default:
+		return nil
+	}
+	if base == nil {
+		return nil
+	}
+	return append(append([]string{}, base...), idents...)
+}
+
+func (l *linter) reference(tree *parse.Tree, node parse.Node, p []string, uses bool) {
+	l.references = append(l.references, lintReference{path: p, uses: uses})
+	if !hasDataPath(l.data, p) {
+		line, column := nodePosition(tree, node)
+		l.add(tree.ParseName, line, column, LintMissingKey, fmt.Sprintf("%s is not in the data", formatDataPath(p)))
+	}
+}
+
+// hasDataPath returns true, if the path exists in the data, elements of lists need to exist in any element
+func hasDataPath(data interface{}, p []string) bool {
+	if len(p) == 0 {
+		return true
+	}
+	if p[0] == listElements {
+		elements, err := getElements(data)
+		if err != nil {
+			return false
+		}
+		for _, element := range elements {
+			if hasDataPath(element, p[1:]) {
+				return true
+			}
+		}
+		return false
+	}
+	m, ok := data.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	value, ok := m[p[0]]
+	return ok && hasDataPath(value, p[1:])
+}
+
+func formatDataPath(p []string) string {
+	return "." + strings.Join(p, ".")
+}
+
+// unusedKeys lists the top most keys of the data, that no reference leads to
+func (l *linter) unusedKeys(args *Args) (findings []LintFinding) {
+	var unused [][]string
+	var find func(data interface{}, p []string)
+	find = func(data interface{}, p []string) {
+		used, descend := false, false
+		for _, reference := range l.references {
+			switch {
+			case reference.uses && isPathPrefix(reference.path, p):
+				used = true
+			case isPathPrefix(p, reference.path):
+				descend = true
+			}
+		}
+		switch {
+		case used:
+		case !descend:
+			unused = append(unused, p)
+		default:
+			switch v := data.(type) {
+			case map[string]interface{}:
+				keys := make([]string, 0, len(v))
+				for key := range v {
+					keys = append(keys, key)
+				}
+				sort.Strings(keys)
+				for _, key := range keys {
+					find(v[key], append(append([]string{}, p...), key))
+				}
+			case []interface{}:
+				// the keys of all elements
+				elements := map[string]interface{}{}
+				for _, element := range v {
+					if m, ok := element.(map[string]interface{}); ok {
+						for key, value := range m {
+							elements[key] = value
+						}
+					}
+				}
+				keys := make([]string, 0, len(elements))
+				for key := range elements {
+					keys = append(keys, key)
+				}
+				sort.Strings(keys)
+				for _, key := range keys {
+					find(elements[key], append(append([]string{}, p...), listElements, key))
+				}
+			}
+		}
+	}
+	m, ok := l.data.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		find(m[key], []string{key})
+	}
+	for _, p := range unused {
+		findings = append(findings, LintFinding{
+			File:    getDataFile(args, p),
+			Kind:    LintUnusedKey,
+			Message: fmt.Sprintf("%s is never referenced", formatDataPath(p)),
+		})
+	}
+	return findings
+}
+
+// isPathPrefix returns true, if prefix is a prefix of p, list elements match any key
+func isPathPrefix(prefix, p []string) bool {
+	if len(prefix) > len(p) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != p[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// getDataFile returns the last data file, that has the path, or "data" for overrides and environment variables
+func getDataFile(args *Args, p []string) string {
+	for i := len(args.DataFiles) - 1; i >= 0; i-- {
+		reader, ok := getDataReader(args.DataFiles[i])
+		if !ok {
+			continue
+		}
+		dataBytes, err := ioutil.ReadFile(args.DataFiles[i])
+		if err != nil {
+			continue
+		}
+		data, err := reader(dataBytes)
+		if err == nil && hasDataPath(normalizeData(data), p) {
+			return args.DataFiles[i]
+		}
+	}
+	return "data"
+}
+
+// lintPatterns reports lines of .bobignore and .bobcopy files, that match no file or folder
+func (l *linter) lintPatterns(folder string) error {
+	type entry struct {
+		relativePath string
+		isDir        bool
+	}
+	var entries []entry
+	prefix := folder + "/"
+	err := walk(folder, nil, func(p string, info os.FileInfo) bool {
+		isDir := false
+		if targetInfo, err := resolve(info, p); err == nil {
+			isDir = targetInfo.IsDir()
+		}
+		entries = append(entries, entry{strings.TrimPrefix(p, prefix), isDir})
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	patternFiles := []string{path.Join(folder, ".bobcopy")}
+	for _, e := range entries {
+		if !e.isDir && path.Base(e.relativePath) == ".bobignore" {
+			patternFiles = append(patternFiles, path.Join(folder, e.relativePath))
+		}
+	}
+	for _, patternFile := range patternFiles {
+		contents, err := ioutil.ReadFile(patternFile)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		patternFolder := strings.TrimPrefix(path.Dir(patternFile), folder)
+		patternFolder = strings.TrimPrefix(patternFolder, "/")
+		for i, patternLine := range strings.Split(string(contents), "\n") {
+			p := parsePattern(strings.TrimSpace(patternLine))
+			if p == nil {
+				continue
+			}
+			matched := false
+			for _, e := range entries {
+				relativePath := e.relativePath
+				if patternFolder != "" {
+					if !strings.HasPrefix(relativePath, patternFolder+"/") {
+						continue
+					}
+					relativePath = strings.TrimPrefix(relativePath, patternFolder+"/")
+				}
+				if p.match(relativePath, e.isDir) {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				l.add(patternFile, i+1, 0, LintUnmatchedPattern, fmt.Sprintf("%q matches nothing", strings.TrimSpace(patternLine)))
+			}
+		}
+	}
+	return nil
+}
+
+func isIdentifier(node parse.Node, name string) bool {
+	identifier, ok := node.(*parse.IdentifierNode)
+	return ok && identifier.Ident == name
+}
+
+func nodePosition(tree *parse.Tree, node parse.Node) (line, column int) {
+	location, _ := tree.ErrorContext(node)
+	parts := strings.Split(location, ":")
+	if len(parts) < 3 {
+		return 0, 0
+	}
+	line, _ = strconv.Atoi(parts[len(parts)-2])
+	column, _ = strconv.Atoi(parts[len(parts)-1])
+	return line, column
+}
+
+// walkCommands calls visit for all commands in a tree
+func walkCommands(node parse.Node, visit func(cmd *parse.CommandNode)) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			walkCommands(child, visit)
+		}
+	case *parse.ActionNode:
+		walkCommands(n.Pipe, visit)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			visit(cmd)
+			for _, arg := range cmd.Args {
+				walkCommands(arg, visit)
+			}
+		}
+	case *parse.IfNode:
+		walkCommands(n.Pipe, visit)
+		walkCommands(n.List, visit)
+		walkCommands(n.ElseList, visit)
+	case *parse.WithNode:
+		walkCommands(n.Pipe, visit)
+		walkCommands(n.List, visit)
+		walkCommands(n.ElseList, visit)
+	case *parse.RangeNode:
+		walkCommands(n.Pipe, visit)
+		walkCommands(n.List, visit)
+		walkCommands(n.ElseList, visit)
+	}
+}
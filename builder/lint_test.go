@@ -0,0 +1,71 @@
+package builder
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	dataFolder := getTestFolder(t, map[string]string{"data.yml": `
+name: bob
+unused: 1
+server:
+  host: localhost
+  port: 80
+  tls: false
+sites:
+  - name: a
+    aliases: [www.a]
+  - name: b
+    unusedInSites: x
+`})
+	source := getTestFolder(t, map[string]string{
+		"app.conf": `{{ .name }} {{ .missing }}
+{{ with .server }}{{ .host }}:{{ .port }}{{ .nope }}{{ end }}
+{{ range $site := .sites }}{{ $site.name }} {{ index $site "aliases" }}{{ end }}
+{{ secret "path/to/secret.prop" }} {{ secret "no-prop" }} {{ $.server.tls }}`,
+		`{{ fanout "site" .sites }}{{ .site.name }}.conf`: "{{ .site.name }} {{ .site.missing }}",
+		"broken.conf":    "{{ if }}",
+		"front.conf":     "---\nmode: '0600'\n---\n{{ .name }}\n{{ .server.missing }}",
+		"frontbroken.md": "---\nmode: '0600'\n---\n{{ if }}",
+		"copied.txt":     "{{ .copied }}",
+		".bobcopy":       "copied.txt\nnothing.txt",
+		".bobignore":     "# comment\n*.bak\n",
+		"sub/.bobignore": "/app.conf\n",
+		"_partials/p":    "{{ .anything }} {{ secret \"a.b.c\" }}",
+	})
+	findings, err := Lint(&Args{
+		DataFiles:     []string{filepath.Join(dataFolder, "data.yml")},
+		SourceFolders: []string{source},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var messages []string
+	for _, finding := range findings {
+		messages = append(messages, finding.String())
+	}
+	dataFile := filepath.Join(dataFolder, "data.yml")
+	want := []string{
+		filepath.Join(source, ".bobcopy") + `:2: unmatched-pattern: "nothing.txt" matches nothing`,
+		filepath.Join(source, ".bobignore") + `:2: unmatched-pattern: "*.bak" matches nothing`,
+		filepath.Join(source, "_partials/p") + `:1:26: secret-syntax: secret key "a.b.c" has to be like "path/to/secret.prop"`,
+		filepath.Join(source, "app.conf") + `:1:15: missing-key: .missing is not in the data`,
+		filepath.Join(source, "app.conf") + `:2:44: missing-key: .server.nope is not in the data`,
+		filepath.Join(source, "app.conf") + `:4:45: secret-syntax: secret key "no-prop" has to be like "path/to/secret.prop"`,
+		filepath.Join(source, "broken.conf") + `:1: parse-error: missing value for if`,
+		filepath.Join(source, "front.conf") + `:5:10: missing-key: .server.missing is not in the data`,
+		filepath.Join(source, "frontbroken.md") + `:4: parse-error: missing value for if`,
+		filepath.Join(source, "sub/.bobignore") + `:1: unmatched-pattern: "/app.conf" matches nothing`,
+		filepath.Join(source, `{{ fanout "site" .sites }}{{ .site.name }}.conf`) + `:1:25: missing-key: .sites.[].missing is not in the data`,
+		dataFile + `: unused-key: .sites.[].unusedInSites is never referenced`,
+		dataFile + `: unused-key: .unused is never referenced`,
+	}
+	sort.Strings(messages)
+	sort.Strings(want)
+	if !reflect.DeepEqual(messages, want) {
+		t.Errorf("Lint() = %q, want %q", messages, want)
+	}
+}
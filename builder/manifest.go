@@ -0,0 +1,102 @@
+package builder
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Manifest lists the inputs and outputs of a build
+type Manifest struct {
+	SourceFolders []string           `json:"sourceFolders"`
+	DataFiles     []ManifestDataFile `json:"dataFiles"`
+	Files         []ManifestFile     `json:"files"`
+}
+
+// ManifestDataFile a data file, that was used for the build
+type ManifestDataFile struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+}
+
+// ManifestFile a file in the target folder and where it came from
+type ManifestFile struct {
+	Path         string `json:"path"`
+	SourceFolder string `json:"sourceFolder"`
+	Source       string `json:"source"`
+	Copied       bool   `json:"copied"`
+	Mode         string `json:"mode"`
+	Owner        string `json:"owner,omitempty"`
+	Group        string `json:"group,omitempty"`
+	Secret       bool   `json:"secret,omitempty"`
+	SHA256       string `json:"sha256"`
+}
+
+// NewManifest describes a processing result and the args it was built with
+func NewManifest(args *Args, result *ProcessingResult) (manifest *Manifest, err error) {
+	manifest = &Manifest{
+		SourceFolders: args.SourceFolders,
+		DataFiles:     []ManifestDataFile{},
+		Files:         []ManifestFile{},
+	}
+	for _, dataFile := range args.DataFiles {
+		dataBytes, err := ioutil.ReadFile(dataFile)
+		if err != nil {
+			return nil, err
+		}
+		manifest.DataFiles = append(manifest.DataFiles, ManifestDataFile{
+			Path:   dataFile,
+			SHA256: hash(dataBytes),
+		})
+	}
+	for file, fr := range result.Files {
+		manifest.Files = append(manifest.Files, ManifestFile{
+			Path:         file,
+			SourceFolder: fr.sourceFolder,
+			Source:       fr.source,
+			Copied:       fr.copied,
+			Mode:         fmt.Sprintf("%#o", fr.mode),
+			Owner:        fr.owner,
+			Group:        fr.group,
+			Secret:       fr.secret,
+			SHA256:       hash(fr.bytes),
+		})
+	}
+	sort.Slice(manifest.Files, func(i, j int) bool {
+		return manifest.Files[i].Path < manifest.Files[j].Path
+	})
+	return manifest, nil
+}
+
+func hash(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// AddManifest adds a manifest of the result as a json file to the result itself, so that it is written, planned and
+// pruned like every other file
+func (p *ProcessingResult) AddManifest(name string, manifest *Manifest) error {
+	name = path.Clean(name)
+	if path.IsAbs(name) || name == "." || name == ".." || strings.HasPrefix(name, "../") {
+		return fmt.Errorf("can not add manifest %q, it has to be a file in the target folder", name)
+	}
+	if _, ok := p.Files[name]; ok {
+		return fmt.Errorf("can not add manifest %q, the build already contains a file with that name", name)
+	}
+	manifestBytes, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	p.Files[name] = &fileResult{
+		filename: name,
+		mode:     0644,
+		bytes:    append(manifestBytes, '\n'),
+	}
+	p.addParentFolders()
+	return nil
+}
@@ -0,0 +1,94 @@
+package builder
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/foomo/config-bob/vault"
+)
+
+func TestManifest(t *testing.T) {
+	vault.Dummy = true
+	args := &Args{
+		DataFiles:     []string{GetExample("data.json")},
+		SourceFolders: []string{GetExample("source-a")},
+	}
+	result, err := Build(args)
+	panicOnErr(err)
+	manifest, err := NewManifest(args, result)
+	panicOnErr(err)
+	if len(manifest.DataFiles) != 1 || len(manifest.DataFiles[0].SHA256) != 64 {
+		t.Errorf("NewManifest() DataFiles = %v", manifest.DataFiles)
+	}
+
+	files := map[string]ManifestFile{}
+	for _, file := range manifest.Files {
+		files[file.Path] = file
+	}
+	if len(files) != 4 {
+		t.Errorf("NewManifest() Files = %v, want 4 files", manifest.Files)
+	}
+	tests := []struct {
+		path   string
+		copied bool
+	}{
+		{"config.yml", false},
+		{"httpd/copy.txt", true},
+		{"httpd/ext/foo.conf", false},
+		{"httpd/test.conf", false},
+	}
+	for _, tt := range tests {
+		info, err := os.Stat(GetExample(filepath.Join("source-a", tt.path)))
+		panicOnErr(err)
+		want := ManifestFile{
+			Path:         tt.path,
+			SourceFolder: GetExample("source-a"),
+			Source:       tt.path,
+			Copied:       tt.copied,
+			Mode:         fmt.Sprintf("%#o", info.Mode().Perm()),
+			SHA256:       hash(result.Files[tt.path].bytes),
+		}
+		if files[tt.path] != want {
+			t.Errorf("NewManifest() file %v, want %v", files[tt.path], want)
+		}
+	}
+
+	panicOnErr(result.AddManifest("meta/manifest.json", manifest))
+	if !result.ContainsFolder("meta") {
+		t.Error("AddManifest() did not add the folder meta")
+	}
+	decoded := &Manifest{}
+	panicOnErr(json.Unmarshal(result.Files["meta/manifest.json"].bytes, decoded))
+	if !reflect.DeepEqual(decoded, manifest) {
+		t.Errorf("AddManifest() wrote %v, want %v", decoded, manifest)
+	}
+}
+
+func TestAddManifestNames(t *testing.T) {
+	manifest := &Manifest{}
+	tests := []struct {
+		name    string
+		wantErr bool
+	}{
+		{"manifest.json", false},
+		{"./meta/../build.json", false},
+		{"httpd/test.conf", true},
+		{"../manifest.json", true},
+		{"meta/../../manifest.json", true},
+		{"/tmp/manifest.json", true},
+		{"..", true},
+		{".", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := getTestResult(t, map[string]string{"httpd/test.conf": ""}, "httpd")
+			if err := result.AddManifest(tt.name, manifest); (err != nil) != tt.wantErr {
+				t.Errorf("AddManifest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package builder
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"text/template/parse"
+)
+
+// MissingKeyPolicy decides what happens, when a template references a key, that is not in the data
+type MissingKeyPolicy string
+
+const (
+	// MissingKeyError fails on the first missing key
+	MissingKeyError MissingKeyPolicy = "error"
+	// MissingKeyZero renders missing keys as zero values
+	MissingKeyZero MissingKeyPolicy = "zero"
+	// MissingKeyReport renders placeholders for missing keys and fails with a list of all of them after the build
+	MissingKeyReport MissingKeyPolicy = "report"
+)
+
+func getMissingKeyPolicy(policy MissingKeyPolicy) (MissingKeyPolicy, error) {
+	switch policy {
+	case "":
+		return MissingKeyError, nil
+	case MissingKeyError, MissingKeyZero, MissingKeyReport:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown missing key policy %q, use %q, %q or %q", policy, MissingKeyError, MissingKeyZero, MissingKeyReport)
+}
+
+// templateOption is the text/template option for the policy
+func (policy MissingKeyPolicy) templateOption() string {
+	if policy == MissingKeyZero {
+		return "missingkey=zero"
+	}
+	return "missingkey=error"
+}
+
+// missingKeyReport collects the missing keys of all templates of a build
+type missingKeyReport struct {
+	lock    sync.Mutex
+	entries []string
+}
+
+func (report *missingKeyReport) add(entry string) {
+	report.lock.Lock()
+	defer report.lock.Unlock()
+	report.entries = append(report.entries, entry)
+}
+
+// err lists all missing keys or returns nil, if there are none
+func (report *missingKeyReport) err() error {
+	if report == nil {
+		return nil
+	}
+	report.lock.Lock()
+	defer report.lock.Unlock()
+	if len(report.entries) == 0 {
+		return nil
+	}
+	entries := append([]string{}, report.entries...)
+	sort.Strings(entries)
+	return fmt.Errorf("%d missing keys:\n%s", len(entries), strings.Join(entries, "\n"))
+}
+
+// missingKeyErrorRegexp matches the errors of text/template for missing map keys, the location is name:line:col
+var missingKeyErrorRegexp = regexp.MustCompile(`^template: (.+:\d+:\d+): executing "[^"]*" at <([^<>]+)>: map has no entry for key "(.*)"$`)
+
+// executeReporting executes the template and replaces every missing key in its parse tree with a placeholder, until
+// it can be executed
+func (r *renderer) executeReporting(t *template.Template) (result []byte, err error) {
+	copied := map[*template.Template]bool{}
+	for {
+		out := &strings.Builder{}
+		err = t.Execute(out, r.data)
+		if err == nil {
+			return []byte(out.String()), nil
+		}
+		// errors of included templates are nested, the innermost one comes last
+		message := err.Error()
+		match := missingKeyErrorRegexp.FindStringSubmatch(message[strings.LastIndex(message, "template: "):])
+		if match == nil {
+			return nil, err
+		}
+		location, field, key := match[1], match[2], match[3]
+		if !replaceMissingKey(t, copied, location, field) {
+			return nil, err
+		}
+		// reported like errors, at the position in the file and in the partials
+		reported := newTemplateError(t.Name(), err).shift(t.Name(), r.lineOffset)
+		reported.Message = fmt.Sprintf("%s (no entry for key %q)", field, key)
+		r.missingKeys.add(reported.Error())
+	}
+}
+
+// replaceMissingKey finds the node at the location in all templates associated with t and replaces it
+func replaceMissingKey(t *template.Template, copied map[*template.Template]bool, location, field string) bool {
+	for _, tmpl := range t.Templates() {
+		if tmpl.Tree == nil {
+			continue
+		}
+		index := -1
+		walkFields(tmpl.Tree.Root, func(i int, n parse.Node, control parse.NodeType, replace func(parse.Node)) {
+			nodeLocation, _ := tmpl.Tree.ErrorContext(n)
+			if index < 0 && nodeLocation == location && n.String() == field {
+				index = i
+			}
+		})
+		if index < 0 {
+			continue
+		}
+		if !copied[tmpl] {
+			// the trees of partials are shared with other files
+			tmpl.Tree = tmpl.Tree.Copy()
+			copied[tmpl] = true
+		}
+		walkFields(tmpl.Tree.Root, func(i int, n parse.Node, control parse.NodeType, replace func(parse.Node)) {
+			if i == index {
+				replace(placeholder(n, control))
+			}
+		})
+		return true
+	}
+	return false
+}
+
+// placeholder is false in if and with pipelines and 0 in range pipelines, so that they are not executed and a
+// string otherwise
+func placeholder(n parse.Node, control parse.NodeType) parse.Node {
+	switch control {
+	case parse.NodeIf, parse.NodeWith:
+		return &parse.BoolNode{NodeType: parse.NodeBool, Pos: n.Position(), True: false}
+	case parse.NodeRange:
+		return &parse.NumberNode{NodeType: parse.NodeNumber, Pos: n.Position(), IsInt: true, Text: "0"}
+	}
+	text := "<missing " + n.String() + ">"
+	return &parse.StringNode{NodeType: parse.NodeString, Pos: n.Position(), Quoted: strconv.Quote(text), Text: text}
+}
+
+// walkFields calls visit for all field, chain and variable nodes in the order of the tree. control is the type of
+// the if, with or range node, whose pipeline consists of nothing but the visited node and parse.NodeText otherwise.
+func walkFields(root *parse.ListNode, visit func(i int, n parse.Node, control parse.NodeType, replace func(parse.Node))) {
+	i := 0
+	var walk func(node parse.Node)
+	walkPipe := func(pipe *parse.PipeNode, control parse.NodeType) {
+		if pipe == nil {
+			return
+		}
+		for _, cmd := range pipe.Cmds {
+			for j, arg := range cmd.Args {
+				switch n := arg.(type) {
+				case *parse.FieldNode, *parse.ChainNode, *parse.VariableNode:
+					cmd, j := cmd, j
+					nodeControl := parse.NodeText
+					if len(pipe.Cmds) == 1 && len(cmd.Args) == 1 {
+						nodeControl = control
+					}
+					visit(i, n, nodeControl, func(replacement parse.Node) {
+						cmd.Args[j] = replacement
+						if nodeControl == parse.NodeRange && len(pipe.Decl) > 1 {
+							// ranging over a number only binds one variable
+							pipe.Decl = pipe.Decl[:1]
+						}
+					})
+					i++
+					if chain, ok := n.(*parse.ChainNode); ok {
+						walk(chain.Node)
+					}
+				default:
+					walk(n)
+				}
+			}
+		}
+	}
+	walkBranch := func(branch *parse.BranchNode) {
+		walkPipe(branch.Pipe, branch.Type())
+		walk(branch.List)
+		walk(branch.ElseList)
+	}
+	walk = func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, child := range n.Nodes {
+				walk(child)
+			}
+		case *parse.ActionNode:
+			walkPipe(n.Pipe, parse.NodeText)
+		case *parse.PipeNode:
+			walkPipe(n, parse.NodeText)
+		case *parse.IfNode:
+			walkBranch(&n.BranchNode)
+		case *parse.WithNode:
+			walkBranch(&n.BranchNode)
+		case *parse.RangeNode:
+			walkBranch(&n.BranchNode)
+		case *parse.TemplateNode:
+			walkPipe(n.Pipe, parse.NodeText)
+		}
+	}
+	walk(root)
+}
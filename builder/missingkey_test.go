@@ -0,0 +1,77 @@
+package builder
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMissingKeyPolicies(t *testing.T) {
+	templates := map[string]string{
+		"a.conf":        "name: {{ .name }}\nhost: {{ .server.host }}\n{{ if .debug }}debug{{ else }}no debug{{ end }}\n{{ range $i, $u := .users }}{{ $u }}{{ else }}no users{{ end }}\n{{ indent .server.port \"  \" }}",
+		"b.conf":        "{{ include \"p\" . }} {{ .name }}",
+		"_partials/p":   "{{ .partial }}",
+		"complete.conf": "{{ .name }}",
+		"c.conf":        "---\nmode: '0600'\n---\n{{ .name }}\n{{ .other }}",
+	}
+	tests := []struct {
+		name        string
+		files       map[string]string
+		missingKey  MissingKeyPolicy
+		want        map[string]string
+		wantErr     bool
+		wantMessage func(source string) string
+	}{
+		{"error", templates, MissingKeyError, nil, true, nil},
+		{"zero", map[string]string{"zero.conf": "{{ .name }}-{{ .partial }}"}, MissingKeyZero, map[string]string{"zero.conf": "bob-<no value>"}, false, nil},
+		{"zero with nested keys of missing keys", templates, MissingKeyZero, nil, true, nil},
+		{"report", templates, MissingKeyReport, nil, true, func(source string) string {
+			a := filepath.Join(source, "a.conf")
+			return "6 missing keys:\n" +
+				a + ":2:16: .server.host (no entry for key \"server\")\n" +
+				a + ":3:6: .debug (no entry for key \"debug\")\n" +
+				a + ":4:19: .users (no entry for key \"users\")\n" +
+				a + ":5:17: .server.port (no entry for key \"server\")\n" +
+				filepath.Join(source, "b.conf") + ":1:3: _partials/p:1:3: .partial (no entry for key \"partial\")\n" +
+				filepath.Join(source, "c.conf") + ":5:3: .other (no entry for key \"other\")"
+		}},
+		{"unknown policy", templates, "ignore", nil, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := getTestFolder(t, tt.files)
+			result, err := Build(&Args{
+				SourceFolders: []string{source},
+				Overrides:     []DataOverride{{Path: "name", Value: "bob"}},
+				MissingKey:    tt.missingKey,
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Build() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantMessage != nil && err.Error() != tt.wantMessage(source) {
+				t.Errorf("Build() error = %q, want %q", err.Error(), tt.wantMessage(source))
+			}
+			if !tt.wantErr && !reflect.DeepEqual(getContents(result), tt.want) {
+				t.Errorf("Build() = %v, want %v", getContents(result), tt.want)
+			}
+		})
+	}
+}
+
+func TestMissingKeyPlaceholders(t *testing.T) {
+	r := &renderer{
+		data:        map[string]interface{}{"name": "bob"},
+		missingKey:  MissingKeyReport,
+		missingKeys: &missingKeyReport{},
+	}
+	result, err := r.process("test", "{{ .name }} {{ .host }}{{ with .port }}:{{ . }}{{ end }}{{ range .users }}x{{ end }}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(result) != "bob <missing .host>" {
+		t.Errorf("process() = %q", result)
+	}
+	if len(r.missingKeys.entries) != 3 {
+		t.Errorf("process() reported %v, want 3 missing keys", r.missingKeys.entries)
+	}
+}
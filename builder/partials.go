@@ -0,0 +1,113 @@
+package builder
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path"
+	"text/template"
+)
+
+// partialsFolder in a source folder contains templates, that can be used in every rendered file
+const partialsFolder = "_partials"
+
+// renderer holds everything templates are rendered with
+type renderer struct {
+	data interface{}
+	// partials from all source folders, later source folders override earlier ones
+	partials *template.Template
+	// delimiters for file templates, empty ones are the defaults
+	leftDelim, rightDelim string
+	missingKey            MissingKeyPolicy
+	// missingKeys collects missing keys with MissingKeyReport
+	missingKeys *missingKeyReport
+	// lineOffset is added to the lines of errors and missing keys in file templates, that do not start at the top
+	// of their file
+	lineOffset int
+}
+
+func newTemplate(name string) *template.Template {
+	return template.New(name).Funcs(TemplateFuncs).Funcs(template.FuncMap{
+		"include": func(name string, data interface{}) (string, error) {
+			return "", nil
+		},
+	})
+}
+
+// loadPartials parses all files in the partials folders of the source folders with the delimiters of their folder
+// config. Every file is available under its path relative to the partials folder and so is every template it defines.
+func loadPartials(sourceFolders []string) (partials *template.Template, err error) {
+	partials = newTemplate("")
+	for _, sourceFolder := range sourceFolders {
+		folder := path.Join(path.Clean(sourceFolder), partialsFolder)
+		configs := newFolderConfigs(path.Clean(sourceFolder))
+		info, err := os.Stat(folder)
+		if os.IsNotExist(err) || (err == nil && !info.IsDir()) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		files, err := getFiles(folder, getIgnore(folder))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			partialBytes, err := ioutil.ReadFile(path.Join(folder, file))
+			if err != nil {
+				return nil, err
+			}
+			config, err := configs.get(path.Join(partialsFolder, path.Dir(file)))
+			if err != nil {
+				return nil, err
+			}
+			partial := partials.New(file)
+			if len(config.Delims) == 2 {
+				partial.Delims(config.Delims[0], config.Delims[1])
+			}
+			_, err = partial.Parse(string(partialBytes))
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return partials, nil
+}
+
+// newFileTemplate creates a template for a file, that knows all partials
+func (r *renderer) newFileTemplate(name string) (t *template.Template, err error) {
+	if r.partials == nil {
+		t = newTemplate(name)
+	} else {
+		t, err = r.partials.Clone()
+		if err != nil {
+			return nil, err
+		}
+		t = t.New(name)
+	}
+	// partials are executed with their own options
+	for _, tmpl := range t.Templates() {
+		tmpl.Option(r.missingKey.templateOption())
+	}
+	return t.Delims(r.leftDelim, r.rightDelim).Funcs(template.FuncMap{
+		"include": func(name string, data interface{}) (string, error) {
+			out := &bytes.Buffer{}
+			err := t.ExecuteTemplate(out, name, data)
+			return out.String(), err
+		},
+	}), nil
+}
+
+// withLineOffset returns a renderer for a file template, that starts after lines lines of its file
+func (r *renderer) withLineOffset(lines int) *renderer {
+	offsetRenderer := *r
+	offsetRenderer.lineOffset = lines
+	return &offsetRenderer
+}
+
+// withDelims returns a renderer, that parses file templates with other delimiters
+func (r *renderer) withDelims(left, right string) *renderer {
+	delimsRenderer := *r
+	delimsRenderer.leftDelim, delimsRenderer.rightDelim = left, right
+	return &delimsRenderer
+}
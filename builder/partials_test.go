@@ -0,0 +1,38 @@
+package builder
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPartials(t *testing.T) {
+	result, err := Build(&Args{
+		SourceFolders: []string{GetExample("source-partials-a"), GetExample("source-partials-b")},
+		Overrides: []DataOverride{
+			{Path: "level", Value: "debug"},
+			{Path: "tls", Value: true},
+			{Path: "name", Value: "bob"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"app.yml":                 "logging:\n  level: debug from b\ntls: true\n",
+		"greet.txt":               "hello bob\n",
+		"sub/_partials/not-a.txt": "rendered, because partials are only picked up at the root\n",
+	}
+	if got := getContents(result); !reflect.DeepEqual(got, want) {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+	if want := []string{"sub", "sub/_partials"}; !reflect.DeepEqual(result.Folders, want) {
+		t.Errorf("Build() Folders = %v, want %v", result.Folders, want)
+	}
+
+	_, err = Build(&Args{SourceFolders: []string{getTestFolder(t, map[string]string{
+		"broken.txt": `{{ include "missing" . }}`,
+	})}})
+	if err == nil {
+		t.Error("Build() expected an error for a missing partial")
+	}
+}
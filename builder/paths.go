@@ -0,0 +1,171 @@
+package builder
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// pathExpansion is an output path of a source file and the renderer for its contents
+type pathExpansion struct {
+	path     string
+	renderer *renderer
+}
+
+var errFanout = errors.New("fanout")
+
+func isTemplatedPath(p string) bool {
+	return strings.Contains(p, "{{")
+}
+
+// expandPath renders a templated path. A path fans out into one path per element of a list with
+// {{ fanout "key" .list }}, which makes the element available as .key in the rest of the path and in the contents.
+func (r *renderer) expandPath(p string) (expansions []pathExpansion, err error) {
+	if !isTemplatedPath(p) {
+		return []pathExpansion{{path: p, renderer: r}}, nil
+	}
+	return r.expandPathWith(p, map[string]bool{})
+}
+
+func (r *renderer) expandPathWith(p string, bound map[string]bool) (expansions []pathExpansion, err error) {
+	var (
+		fanoutKey  string
+		fanoutList interface{}
+	)
+	t, err := template.New(p).Option("missingkey=error").Funcs(TemplateFuncs).Funcs(template.FuncMap{
+		"fanout": func(key string, list interface{}) (string, error) {
+			if bound[key] {
+				return "", nil
+			}
+			// the rest of the path can only be rendered, once the key is bound
+			fanoutKey, fanoutList = key, list
+			return "", errFanout
+		},
+	}).Parse(p)
+	if err != nil {
+		return nil, err
+	}
+	out := &bytes.Buffer{}
+	err = t.Execute(out, r.data)
+	if err != nil && !errors.Is(err, errFanout) {
+		return nil, err
+	}
+	if fanoutKey == "" {
+		renderedPath, err := cleanRenderedPath(p, out.String())
+		if err != nil {
+			return nil, err
+		}
+		return []pathExpansion{{path: renderedPath, renderer: r}}, nil
+	}
+	elements, err := getElements(fanoutList)
+	if err != nil {
+		return nil, fmt.Errorf("can not fan out %q over %q: %q", p, fanoutKey, err)
+	}
+	elementBound := map[string]bool{fanoutKey: true}
+	for key := range bound {
+		elementBound[key] = true
+	}
+	for _, element := range elements {
+		elementRenderer, err := r.withValue(fanoutKey, element)
+		if err != nil {
+			return nil, err
+		}
+		elementExpansions, err := elementRenderer.expandPathWith(p, elementBound)
+		if err != nil {
+			return nil, err
+		}
+		expansions = append(expansions, elementExpansions...)
+	}
+	return expansions, nil
+}
+
+func cleanRenderedPath(p, renderedPath string) (string, error) {
+	cleanPath := path.Clean(renderedPath)
+	if renderedPath == "" || cleanPath == "." || path.IsAbs(cleanPath) || cleanPath == ".." || strings.HasPrefix(cleanPath, "../") {
+		return "", fmt.Errorf("path %q rendered to invalid path %q", p, renderedPath)
+	}
+	return cleanPath, nil
+}
+
+// getElements returns the elements of slices and arrays and the values of maps ordered by their keys
+func getElements(list interface{}) (elements []interface{}, err error) {
+	value := reflect.ValueOf(list)
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			elements = append(elements, value.Index(i).Interface())
+		}
+	case reflect.Map:
+		keys := value.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		for _, key := range keys {
+			elements = append(elements, value.MapIndex(key).Interface())
+		}
+	default:
+		return nil, errors.New("only lists and maps can be fanned out")
+	}
+	return elements, nil
+}
+
+// withValue returns a renderer with a shallow copy of the data, that has the value set for the key
+func (r *renderer) withValue(key string, value interface{}) (*renderer, error) {
+	data := map[string]interface{}{}
+	if r.data != nil {
+		m, ok := r.data.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("can not set %q on data of type %T", key, r.data)
+		}
+		for k, v := range m {
+			data[k] = v
+		}
+	}
+	data[key] = value
+	elementRenderer := *r
+	elementRenderer.data = data
+	return &elementRenderer, nil
+}
+
+// expandFolders renders templated folder names
+func (r *renderer) expandFolders(folderPath string, folders []string) (expandedFolders []string, errs Errors) {
+	for _, folder := range folders {
+		expansions, err := r.expandPath(folder)
+		if err != nil {
+			errs = errs.add(&TemplateError{File: path.Join(folderPath, folder), Message: "could not render path: " + err.Error(), Err: err})
+			continue
+		}
+		for _, expansion := range expansions {
+			if !containsString(expandedFolders, expansion.path) {
+				expandedFolders = append(expandedFolders, expansion.path)
+			}
+		}
+	}
+	return expandedFolders, errs
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// addParentFolders makes sure, that the parent folders of all files are part of the result
+func (p *ProcessingResult) addParentFolders() {
+	for file := range p.Files {
+		for folder := path.Dir(file); folder != "." && folder != "/"; folder = path.Dir(folder) {
+			if !p.ContainsFolder(folder) {
+				p.Folders = append(p.Folders, folder)
+			}
+		}
+	}
+	sort.Strings(p.Folders)
+}
@@ -0,0 +1,107 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestTemplatedPaths(t *testing.T) {
+	source := getTestFolder(t, map[string]string{
+		"{{ .env }}.conf": "env {{ .env }}",
+		`{{ fanout "app" .apps }}{{ .app.name }}/config.yml`:                                "port: {{ .app.port }}",
+		`sites/{{ fanout "site" .sites }}{{ .site }}.conf`:                                  "server_name {{ .site }};",
+		`{{ fanout "app" .apps }}{{ fanout "site" .sites }}{{ .app.name }}-{{ .site }}.txt`: "{{ .app.name }} {{ .site }}",
+		"plain.txt": "plain",
+	})
+	result, err := Build(&Args{
+		SourceFolders: []string{source},
+		Overrides: []DataOverride{
+			{Path: "env", Value: "prod"},
+			{Path: "apps", Value: []interface{}{
+				map[string]interface{}{"name": "api", "port": 8080},
+				map[string]interface{}{"name": "web", "port": 80},
+			}},
+			{Path: "sites", Value: map[string]interface{}{"b": "b.local", "a": "a.local"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"prod.conf":          "env prod",
+		"api/config.yml":     "port: 8080",
+		"web/config.yml":     "port: 80",
+		"sites/a.local.conf": "server_name a.local;",
+		"sites/b.local.conf": "server_name b.local;",
+		"api-a.local.txt":    "api a.local",
+		"api-b.local.txt":    "api b.local",
+		"web-a.local.txt":    "web a.local",
+		"web-b.local.txt":    "web b.local",
+		"plain.txt":          "plain",
+	}
+	if got := getContents(result); !reflect.DeepEqual(got, want) {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+	if want := []string{"api", "sites", "web"}; !reflect.DeepEqual(result.Folders, want) {
+		t.Errorf("Build() Folders = %v, want %v", result.Folders, want)
+	}
+	if source := result.Files["api/config.yml"].source; source != `{{ fanout "app" .apps }}{{ .app.name }}/config.yml` {
+		t.Errorf("Build() source of api/config.yml = %q", source)
+	}
+}
+
+func TestTemplatedPathErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+	}{
+		{"collision", map[string]string{"{{ .a }}.txt": "", "{{ .b }}.txt": ""}},
+		{"existing", map[string]string{"{{ .a }}.txt": "", "x.txt": ""}},
+		{"empty", map[string]string{"{{ .missing }}": ""}},
+		{"escape", map[string]string{"{{ .up }}/x.txt": ""}},
+		{"scalar", map[string]string{`{{ fanout "x" .a }}{{ .x }}.txt`: ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Build(&Args{
+				SourceFolders: []string{getTestFolder(t, tt.files)},
+				Overrides: []DataOverride{
+					{Path: "a", Value: "x"},
+					{Path: "b", Value: "x"},
+					{Path: "up", Value: ".."},
+				},
+			})
+			if err == nil {
+				t.Error("Build() expected an error")
+			}
+		})
+	}
+}
+
+func TestTemplatedPathErrorsCollected(t *testing.T) {
+	files := map[string]string{
+		"bad{{ end }}/x.txt":       "",
+		"other{{ end }}/y.txt":     "",
+		"broken/.bobconfig":        "delims: ['[[']",
+		"broken/a.txt":             "",
+		"broken/b.txt":             "",
+		"{{ fanout \"x\" 1 }}.txt": "",
+	}
+	// these fail concurrently, while the path errors are collected
+	for i := 0; i < 20; i++ {
+		files[fmt.Sprintf("a%02d.txt", i)] = "{{ .a.b }}"
+	}
+	_, err := Build(&Args{
+		SourceFolders: []string{getTestFolder(t, files)},
+		Overrides:     []DataOverride{{Path: "a", Value: "x"}},
+	})
+	var errs Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Build() error = %v, want Errors", err)
+	}
+	if len(errs) != 27 {
+		t.Errorf("Build() got %d errors, want 27: %v", len(errs), err)
+	}
+}
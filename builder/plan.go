@@ -0,0 +1,210 @@
+package builder
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// FileAction what writing a processing result would do to a file
+type FileAction string
+
+const (
+	FileActionAdd       FileAction = "add"
+	FileActionChange    FileAction = "change"
+	FileActionUnchanged FileAction = "unchanged"
+)
+
+// FilePlan planned action for a single file in the target folder
+type FilePlan struct {
+	Path    string
+	Action  FileAction
+	Mode    os.FileMode
+	OldMode os.FileMode
+	Diff    string
+}
+
+// Plan describes what WriteProcessingResult would do to a target folder
+type Plan struct {
+	TargetFolder       string
+	CreateTargetFolder bool
+	Folders            []string
+	Files              []*FilePlan
+	Removals           []string
+}
+
+// GetPlan compares a processing result with the contents of a target folder without touching it
+func GetPlan(targetFolder string, result *ProcessingResult) (plan *Plan, err error) {
+	plan = &Plan{
+		TargetFolder: targetFolder,
+	}
+	if _, err := os.Stat(targetFolder); os.IsNotExist(err) {
+		plan.CreateTargetFolder = true
+	}
+	for _, folder := range result.Folders {
+		info, err := os.Stat(path.Join(targetFolder, folder))
+		if os.IsNotExist(err) {
+			plan.Folders = append(plan.Folders, folder)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("%q is not a folder in the target", folder)
+		}
+	}
+	sort.Strings(plan.Folders)
+
+	var files []string
+	for file := range result.Files {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		filePlan, err := planFile(targetFolder, file, result.Files[file])
+		if err != nil {
+			return nil, err
+		}
+		plan.Files = append(plan.Files, filePlan)
+	}
+	return plan, nil
+}
+
+func planFile(targetFolder, file string, fr *fileResult) (*FilePlan, error) {
+	filePlan := &FilePlan{
+		Path: file,
+		Mode: fr.mode,
+	}
+	targetFile := path.Join(targetFolder, file)
+	info, err := os.Stat(targetFile)
+	if os.IsNotExist(err) {
+		filePlan.Action = FileActionAdd
+		return filePlan, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%q is a folder in the target, but would be written as a file", file)
+	}
+	oldBytes, err := ioutil.ReadFile(targetFile)
+	if err != nil {
+		return nil, err
+	}
+	filePlan.OldMode = info.Mode().Perm()
+	if bytes.Equal(oldBytes, fr.bytes) && filePlan.OldMode == filePlan.Mode {
+		filePlan.Action = FileActionUnchanged
+		return filePlan, nil
+	}
+	filePlan.Action = FileActionChange
+	if fr.secret {
+		if !bytes.Equal(oldBytes, fr.bytes) {
+			filePlan.Diff = "secret contents differ\n"
+		}
+		return filePlan, nil
+	}
+	filePlan.Diff, err = diff(file, oldBytes, fr.bytes)
+	return filePlan, err
+}
+
+func diff(file string, oldBytes, newBytes []byte) (string, error) {
+	if bytes.Equal(oldBytes, newBytes) {
+		return "", nil
+	}
+	if bytes.IndexByte(oldBytes, 0) > -1 || bytes.IndexByte(newBytes, 0) > -1 {
+		return "binary files differ\n", nil
+	}
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(string(oldBytes)),
+		B:        splitLines(string(newBytes)),
+		FromFile: path.Join("a", file),
+		ToFile:   path.Join("b", file),
+		Context:  3,
+	})
+}
+
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		return lines[:len(lines)-1]
+	}
+	lines[len(lines)-1] += "\n"
+	return lines
+}
+
+// IncludePrune adds the files and folders, that Prune would remove from the target folder
+func (p *Plan) IncludePrune(result *ProcessingResult, protected []string) error {
+	files, folders, err := getStale(p.TargetFolder, result, protected)
+	if err != nil {
+		return err
+	}
+	p.Removals = files
+	for _, folder := range folders {
+		p.Removals = append(p.Removals, folder+"/")
+	}
+	return nil
+}
+
+// Count the number of files planned with the given action
+func (p *Plan) Count(action FileAction) (count int) {
+	for _, filePlan := range p.Files {
+		if filePlan.Action == action {
+			count++
+		}
+	}
+	return count
+}
+
+// HasChanges true, if writing the result would change the target folder
+func (p *Plan) HasChanges() bool {
+	return p.CreateTargetFolder || len(p.Folders) > 0 || len(p.Removals) > 0 || p.Count(FileActionUnchanged) < len(p.Files)
+}
+
+// Print a human readable version of the plan
+func (p *Plan) Print(w io.Writer) {
+	fmt.Fprintln(w, line)
+	fmt.Fprintln(w, "plan for target folder", p.TargetFolder)
+	fmt.Fprintln(w, line)
+	if p.CreateTargetFolder {
+		fmt.Fprintln(w, "create folder :", p.TargetFolder)
+	}
+	for _, folder := range p.Folders {
+		fmt.Fprintln(w, "create folder :", folder)
+	}
+	for _, filePlan := range p.Files {
+		switch filePlan.Action {
+		case FileActionAdd:
+			fmt.Fprintln(w, "add           :", filePlan.Mode, filePlan.Path)
+		case FileActionChange:
+			if filePlan.OldMode != filePlan.Mode {
+				fmt.Fprintln(w, "change        :", filePlan.OldMode, "->", filePlan.Mode, filePlan.Path)
+			} else {
+				fmt.Fprintln(w, "change        :", filePlan.Mode, filePlan.Path)
+			}
+			fmt.Fprint(w, filePlan.Diff)
+		case FileActionUnchanged:
+			fmt.Fprintln(w, "unchanged     :", filePlan.Mode, filePlan.Path)
+		}
+	}
+	for _, removal := range p.Removals {
+		fmt.Fprintln(w, "remove        :", removal)
+	}
+	fmt.Fprintln(w, line)
+	fmt.Fprintf(
+		w,
+		"%d folders to create, %d files to add, %d to change, %d unchanged, %d to remove\n",
+		len(p.Folders),
+		p.Count(FileActionAdd),
+		p.Count(FileActionChange),
+		p.Count(FileActionUnchanged),
+		len(p.Removals),
+	)
+}
@@ -0,0 +1,124 @@
+package builder
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// getTestFolder writes files into a temporary folder, that is removed after the test
+func getTestFolder(t *testing.T, files map[string]string) string {
+	folder, err := ioutil.TempDir(os.TempDir(), "bob-test-")
+	panicOnErr(err)
+	t.Cleanup(func() { _ = os.RemoveAll(folder) })
+	for file, contents := range files {
+		writeTestFile(filepath.Join(folder, file), contents)
+	}
+	return folder
+}
+
+func writeTestFile(filename, contents string) {
+	panicOnErr(os.MkdirAll(filepath.Dir(filename), 0755))
+	panicOnErr(ioutil.WriteFile(filename, []byte(contents), 0644))
+}
+
+// getTestResult is a processing result of files, that have been rendered from a temporary source folder
+func getTestResult(t *testing.T, files map[string]string, folders ...string) *ProcessingResult {
+	sources := map[string]string{}
+	for file, contents := range files {
+		sources[strings.Replace(file, "/", "_", -1)] = contents
+	}
+	sourceFolder := getTestFolder(t, sources)
+	result := &ProcessingResult{
+		Folders: folders,
+		Files:   map[string]*fileResult{},
+	}
+	for file, contents := range files {
+		result.Files[file] = &fileResult{
+			sourceFolder: sourceFolder,
+			source:       file,
+			filename:     filepath.Join(sourceFolder, strings.Replace(file, "/", "_", -1)),
+			bytes:        []byte(contents),
+			mode:         0644,
+		}
+	}
+	return result
+}
+
+func TestGetPlan(t *testing.T) {
+	tests := []struct {
+		name             string
+		target           map[string]string
+		missingTarget    bool
+		files            map[string]string
+		folders          []string
+		wantCreateTarget bool
+		wantFolders      []string
+		wantActions      map[string]FileAction
+		wantDiff         []string
+	}{
+		{"existing target", map[string]string{
+			"same.txt":         "same\n",
+			"httpd/test.conf":  "port 80\nname foo\n",
+			"not-in-build.txt": "whatever",
+		}, false, map[string]string{
+			"same.txt":        "same\n",
+			"httpd/test.conf": "port 8080\nname foo\n",
+			"conf.d/new.conf": "new",
+		}, []string{"httpd", "conf.d"}, false, []string{"conf.d"}, map[string]FileAction{
+			"same.txt":        FileActionUnchanged,
+			"httpd/test.conf": FileActionChange,
+			"conf.d/new.conf": FileActionAdd,
+		}, []string{"-port 80\n", "+port 8080\n"}},
+		{"missing target", nil, true, map[string]string{
+			"a/b.txt": "b",
+		}, []string{"a"}, true, []string{"a"}, map[string]FileAction{
+			"a/b.txt": FileActionAdd,
+		}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			targetFolder := getTestFolder(t, tt.target)
+			if tt.missingTarget {
+				targetFolder = filepath.Join(targetFolder, "does", "not", "exist")
+			}
+			result := getTestResult(t, tt.files, tt.folders...)
+			plan, err := GetPlan(targetFolder, result)
+			panicOnErr(err)
+			if plan.CreateTargetFolder != tt.wantCreateTarget {
+				t.Errorf("GetPlan() CreateTargetFolder = %v, want %v", plan.CreateTargetFolder, tt.wantCreateTarget)
+			}
+			if !reflect.DeepEqual(plan.Folders, tt.wantFolders) {
+				t.Errorf("GetPlan() Folders = %v, want %v", plan.Folders, tt.wantFolders)
+			}
+			actions := map[string]FileAction{}
+			diffs := ""
+			for _, filePlan := range plan.Files {
+				actions[filePlan.Path] = filePlan.Action
+				diffs += filePlan.Diff
+			}
+			if !reflect.DeepEqual(actions, tt.wantActions) {
+				t.Errorf("GetPlan() actions = %v, want %v", actions, tt.wantActions)
+			}
+			for _, line := range tt.wantDiff {
+				if !strings.Contains(diffs, line) {
+					t.Errorf("GetPlan() diff %q does not contain %q", diffs, line)
+				}
+			}
+			if !plan.HasChanges() {
+				t.Error("GetPlan() has no changes")
+			}
+
+			// writing it should make the plan empty
+			panicOnErr(WriteProcessingResult(targetFolder, result))
+			plan, err = GetPlan(targetFolder, result)
+			panicOnErr(err)
+			if plan.HasChanges() {
+				t.Errorf("GetPlan() after writing has changes: %v", plan.Files)
+			}
+		})
+	}
+}
@@ -2,27 +2,37 @@ package builder
 
 import (
 	"bytes"
-	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path"
+	"sort"
 	"strings"
-	"text/template"
-
-	"github.com/foomo/config-bob/vault"
-	"golang.org/x/sync/errgroup"
+	"sync"
 )
 
 type fileResult struct {
-	info     os.FileInfo
+	sourceFolder string
+	// source path of the template relative to the source folder
+	source   string
 	filename string
-	bytes    []byte
+	// rename is the rendered path from the front matter relative to the folder of the file
+	rename string
+	copied bool
+	mode   os.FileMode
+	owner  string
+	group  string
+	secret bool
+	// schemas are json schema files, that the output is validated against
+	schemas []string
+	bytes   []byte
 }
 
 type ProcessingResult struct {
 	Folders []string
 	Files   map[string]*fileResult
+	// Skipped files by the path they would have been rendered to
+	Skipped map[string]*SkippedFile
 }
 
 func (p *ProcessingResult) Merge(otherResult *ProcessingResult) {
@@ -33,6 +43,15 @@ func (p *ProcessingResult) Merge(otherResult *ProcessingResult) {
 	}
 	for filePath, fileBytes := range otherResult.Files {
 		p.Files[filePath] = fileBytes
+		delete(p.Skipped, filePath)
+	}
+	// skipping a file in a later source folder drops it from the earlier ones, too
+	for filePath, skipped := range otherResult.Skipped {
+		if p.Skipped == nil {
+			p.Skipped = map[string]*SkippedFile{}
+		}
+		p.Skipped[filePath] = skipped
+		delete(p.Files, filePath)
 	}
 }
 
@@ -45,107 +64,190 @@ func (p *ProcessingResult) ContainsFolder(someFolder string) bool {
 	return false
 }
 
-func processFolder(folderPath string, data interface{}) (result *ProcessingResult, err error) {
+func processFolder(folderPath string, r *renderer) (result *ProcessingResult, err error) {
 	folderPath = path.Clean(folderPath)
 	ignore := getIgnore(folderPath)
-	if len(ignore) > 2 {
+	// there are four defaults
+	if len(ignore) > 4 {
 		fmt.Println("found .bobignore, ignoring", strings.Join(ignore, ", "))
 	}
 	copiedFiles := getCopy(folderPath)
 	if len(copiedFiles) > 0 {
 		fmt.Println("found .bobcopy, copying", strings.Join(copiedFiles, ", "))
 	}
+	copied := newPatternMatcher(folderPath, copiedFiles, "")
 	folders, err := getFolders(folderPath, ignore)
 	if err != nil {
 		return
 	}
+	folders, errs := r.expandFolders(folderPath, folders)
 	p := &ProcessingResult{
 		Folders: folders,
 		Files:   map[string]*fileResult{},
+		Skipped: map[string]*SkippedFile{},
 	}
 	files, err := getFiles(folderPath, ignore)
 	if err != nil {
 		return nil, err
 	}
 
-	g := errgroup.Group{}
+	type processed struct {
+		file, path string
+		fileResult *fileResult
+		skipped    *SkippedFile
+	}
+	var processedFiles []*processed
+	configs := newFolderConfigs(folderPath)
+	wg := sync.WaitGroup{}
+	lock := sync.Mutex{}
 	for _, file := range files {
-		run := true
-
-		for _, copyFile := range copiedFiles {
-			if strings.HasPrefix(file, copyFile) || file == copyFile {
-				run = false
-				break
-			}
+		run := !copied.matches(file, false)
+		config, err := configs.get(path.Dir(file))
+		if err != nil {
+			// the goroutines of the files before add their errors concurrently
+			lock.Lock()
+			errs = errs.add(err)
+			lock.Unlock()
+			continue
 		}
-		g.Go(func() error {
-			file := file
-			p.Files[file], err = processFile(path.Join(folderPath, file), data, run)
-			if err != nil {
-				return err
-			}
-			return nil
-		})
-	}
-
-	return p, g.Wait()
-}
-
-func rawSecret(key string) (v string, err error) {
-	parts := strings.Split(key, ".")
-	if len(parts) == 2 {
-		secretData, err := vault.Read(parts[0])
+		expansions, err := r.expandPath(file)
 		if err != nil {
-			v = "secret retrieval error: " + err.Error()
-			return v, errors.New(v)
+			lock.Lock()
+			errs = errs.add(&TemplateError{File: path.Join(folderPath, file), Message: "could not render path: " + err.Error(), Err: err})
+			lock.Unlock()
+			continue
 		}
-		prop := parts[1]
-		s, ok := secretData[prop]
-		if !ok {
-			return "<prop not found on secret>", errors.New("property \"" + prop + "\" is not set for secret " + parts[0] + " " + fmt.Sprint(secretData))
+		for _, expansion := range expansions {
+			file, expansion := file, expansion
+			expansion.renderer = config.renderer(expansion.renderer)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				filename := path.Join(folderPath, file)
+				pf := &processed{file: file, path: expansion.path}
+				fileResult, err := processFile(filename, expansion.renderer, run)
+				if reason, ok := getSkipReason(err); ok {
+					pf.skipped = &SkippedFile{Source: filename, Reason: reason}
+					err = nil
+				} else if err == nil {
+					fileResult.sourceFolder = folderPath
+					fileResult.source = file
+					pf.fileResult = fileResult
+					if fileResult.rename != "" {
+						pf.path, err = cleanRenderedPath(fileResult.rename, path.Join(path.Dir(expansion.path), fileResult.rename))
+					}
+				}
+				lock.Lock()
+				defer lock.Unlock()
+				if err != nil {
+					errs = errs.add(newTemplateError(filename, err))
+					return
+				}
+				processedFiles = append(processedFiles, pf)
+			}()
 		}
-		return s, nil
 	}
-	v = "syntax error key must be \"path/to/secret.prop\""
-	return v, errors.New(v)
+	wg.Wait()
+	sort.Slice(processedFiles, func(i, j int) bool {
+		return processedFiles[i].file < processedFiles[j].file
+	})
+	sources := map[string]string{}
+	for _, pf := range processedFiles {
+		if source, ok := sources[pf.path]; ok {
+			errs = errs.add(fmt.Errorf("%q and %q both render to %q", path.Join(folderPath, source), path.Join(folderPath, pf.file), pf.path))
+			continue
+		}
+		sources[pf.path] = pf.file
+		if pf.skipped != nil {
+			p.Skipped[pf.path] = pf.skipped
+			continue
+		}
+		// loaded without errors before
+		config, _ := configs.get(path.Dir(pf.file))
+		pf.fileResult.schemas = config.getSchemas(pf.path)
+		p.Files[pf.path] = pf.fileResult
+	}
+	if err := errs.err(); err != nil {
+		return nil, err
+	}
+	p.addParentFolders()
+	return p, nil
 }
 
-func processFile(filename string, data interface{}, run bool) (result *fileResult, err error) {
+func processFile(filename string, r *renderer, run bool) (result *fileResult, err error) {
 	fileContents, err := ioutil.ReadFile(filename)
 	if err != nil {
-		return nil, nil
+		return nil, err
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+	result = &fileResult{
+		filename: filename,
+		copied:   !run,
+		mode:     info.Mode().Perm(),
+	}
+	if !run {
+		fmt.Println("copying    :", filename)
+		result.bytes = fileContents
+		return result, nil
+	}
+	fmt.Println("processing :", filename)
+	fm, templ, err := splitFrontMatter(fileContents)
+	if err != nil {
+		return nil, err
+	}
+	if len(fm.Delims) == 2 {
+		r = r.withDelims(fm.Delims[0], fm.Delims[1])
+	}
+	if fm.lines > 0 {
+		r = r.withLineOffset(fm.lines)
+	}
+	result.mode, err = fm.getMode(result.mode)
+	if err != nil {
+		return nil, err
 	}
-	var byteData []byte
-	if run {
-		fmt.Println("processing :", filename)
-		processedBytes, err := process(filename, string(fileContents), data)
+	result.owner, result.group, result.secret = fm.Owner, fm.Group, fm.Secret
+	if fm.Path != "" {
+		rename, err := r.process(filename+" front matter path", fm.Path)
 		if err != nil {
 			return nil, err
 		}
-		byteData = processedBytes
-	} else {
-		fmt.Println("copying    :", filename)
-		byteData = fileContents
+		result.rename = string(rename)
 	}
-
-	info, err := os.Stat(filename)
+	result.bytes, err = r.process(filename, string(templ))
 	if err != nil {
 		return nil, err
 	}
-	return &fileResult{
-		filename: filename,
-		bytes:    byteData,
-		info:     info,
-	}, nil
-
+	return result, nil
 }
 
 func process(templName, templ string, data interface{}) (result []byte, err error) {
-	t, err := template.New(templName).Option("missingkey=error").Funcs(TemplateFuncs).Parse(templ)
+	return (&renderer{data: data}).process(templName, templ)
+}
+
+func (r *renderer) process(templName, templ string) (result []byte, err error) {
+	result, err = r.render(templName, templ)
+	if err != nil && r.lineOffset > 0 {
+		return nil, newTemplateError(templName, err).shift(templName, r.lineOffset)
+	}
+	return result, err
+}
+
+func (r *renderer) render(templName, templ string) (result []byte, err error) {
+	t, err := r.newFileTemplate(templName)
 	if err != nil {
 		return
 	}
+	t, err = t.Option(r.missingKey.templateOption()).Parse(templ)
+	if err != nil {
+		return
+	}
+	if r.missingKey == MissingKeyReport {
+		return r.executeReporting(t)
+	}
 	out := bytes.NewBuffer([]byte{})
-	err = t.Execute(out, data)
+	err = t.Execute(out, r.data)
 	return out.Bytes(), err
 }
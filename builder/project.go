@@ -0,0 +1,393 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/foomo/config-bob/vault"
+	"gopkg.in/yaml.v2"
+)
+
+// DefaultProjectFile is the name of the project file, that is used, when none is given
+const DefaultProjectFile = "bob.yaml"
+
+// Project describes named build targets, so that they do not need to be spelled out in positional arguments
+type Project struct {
+	Targets map[string]*ProjectTarget `yaml:"targets"`
+	// Profiles are layered on top of all targets
+	Profiles map[string]*ProjectProfile `yaml:"profiles"`
+}
+
+// ProjectProfile changes targets for an environment like dev or prod. Data files are added after the ones of the
+// target, set values are merged and the target folder and secret settings are replaced.
+type ProjectProfile struct {
+	Data    []string               `yaml:"data"`
+	Set     map[string]interface{} `yaml:"set"`
+	Target  string                 `yaml:"target"`
+	Secrets *SecretSettings        `yaml:"secrets"`
+}
+
+// ProjectTarget is everything a build needs, paths are relative to the folder of the project file
+type ProjectTarget struct {
+	Sources []string `yaml:"sources"`
+	Data    []string `yaml:"data"`
+	Target  string   `yaml:"target"`
+	// Set maps dot separated paths to values, that are set on top of the data files
+	Set          map[string]interface{} `yaml:"set"`
+	EnvPrefix    string                 `yaml:"envPrefix"`
+	ListMerge    ListMergeStrategy      `yaml:"listMerge"`
+	ListMergeKey string                 `yaml:"listMergeKey"`
+	MissingKey   MissingKeyPolicy       `yaml:"missingKey"`
+	Validate     bool                   `yaml:"validate"`
+	Manifest     string                 `yaml:"manifest"`
+	Plan         bool                   `yaml:"plan"`
+	Prune        bool                   `yaml:"prune"`
+	Protect      []string               `yaml:"protect"`
+	Atomic       bool                   `yaml:"atomic"`
+	// Keep defaults to 1
+	Keep    int             `yaml:"keep"`
+	Secrets *SecretSettings `yaml:"secrets"`
+	// Profiles of the target are layered on top of the profiles of the project with the same name
+	Profiles map[string]*ProjectProfile `yaml:"profiles"`
+}
+
+// SecretBackend reads the secrets of the secret template function
+type SecretBackend string
+
+const (
+	// SecretBackendVault reads secrets from vault and the other registered secret providers
+	SecretBackendVault SecretBackend = "vault"
+	// SecretBackendDummy does not read secrets at all and renders placeholders like dummy:path/to/secret.prop
+	SecretBackendDummy SecretBackend = "dummy"
+)
+
+// SecretSettings configure where secrets are read from
+type SecretSettings struct {
+	// Backend defaults to SecretBackendVault
+	Backend SecretBackend  `yaml:"backend"`
+	Vault   *VaultSettings `yaml:"vault"`
+}
+
+// VaultSettings are passed to the vault client in place of its environment variables, the token is not part of the
+// project file
+type VaultSettings struct {
+	Address    string `yaml:"address"`
+	Namespace  string `yaml:"namespace"`
+	CACert     string `yaml:"caCert"`
+	SkipVerify bool   `yaml:"skipVerify"`
+	// Auth logs in instead of using VAULT_TOKEN
+	Auth *VaultAuthSettings `yaml:"auth"`
+}
+
+// VaultAuthSettings are passed in place of the environment variables of vault.AuthFromEnv, secret ids and passwords
+// can only be read from files or the environment
+type VaultAuthSettings struct {
+	// Method is approle, kubernetes, userpass or token
+	Method       string `yaml:"method"`
+	Mount        string `yaml:"mount"`
+	RoleID       string `yaml:"roleID"`
+	RoleIDFile   string `yaml:"roleIDFile"`
+	SecretIDFile string `yaml:"secretIDFile"`
+	// Role of kubernetes auth
+	Role         string `yaml:"role"`
+	JWTFile      string `yaml:"jwtFile"`
+	Username     string `yaml:"username"`
+	PasswordFile string `yaml:"passwordFile"`
+}
+
+// LoadProject reads a project file and resolves all paths relative to its folder
+func LoadProject(filename string) (project *Project, err error) {
+	projectBytes, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	project = &Project{}
+	err = yaml.UnmarshalStrict(projectBytes, project)
+	if err != nil {
+		return nil, fmt.Errorf("invalid project file %q: %q", filename, err)
+	}
+	if len(project.Targets) == 0 {
+		return nil, fmt.Errorf("project file %q has no targets", filename)
+	}
+	folder := filepath.Dir(filename)
+	for name, profile := range project.Profiles {
+		err = profile.resolve(folder)
+		if err != nil {
+			return nil, fmt.Errorf("profile %q in %q: %q", name, filename, err)
+		}
+	}
+	for name, target := range project.Targets {
+		if target == nil {
+			return nil, fmt.Errorf("target %q in %q is empty", name, filename)
+		}
+		err = target.resolve(folder)
+		if err != nil {
+			return nil, fmt.Errorf("target %q in %q: %q", name, filename, err)
+		}
+	}
+	return project, nil
+}
+
+func (t *ProjectTarget) resolve(folder string) error {
+	if len(t.Sources) == 0 {
+		return errors.New("there has to be at least one source folder")
+	}
+	if t.Target == "" {
+		return errors.New("there has to be a target folder")
+	}
+	for i, source := range t.Sources {
+		t.Sources[i] = resolvePath(folder, source)
+	}
+	err := resolveDataFiles(folder, t.Data)
+	if err != nil {
+		return err
+	}
+	t.Target = resolvePath(folder, t.Target)
+	for name, profile := range t.Profiles {
+		err = profile.resolve(folder)
+		if err != nil {
+			return fmt.Errorf("profile %q: %q", name, err)
+		}
+	}
+	return t.Secrets.resolve(folder)
+}
+
+func (p *ProjectProfile) resolve(folder string) error {
+	if p == nil {
+		return errors.New("profile is empty")
+	}
+	err := resolveDataFiles(folder, p.Data)
+	if err != nil {
+		return err
+	}
+	if p.Target != "" {
+		p.Target = resolvePath(folder, p.Target)
+	}
+	return p.Secrets.resolve(folder)
+}
+
+func (s *SecretSettings) resolve(folder string) error {
+	if s == nil {
+		return nil
+	}
+	switch s.Backend {
+	case "", SecretBackendVault, SecretBackendDummy:
+	default:
+		return fmt.Errorf("unknown secret backend %q, use %q or %q", s.Backend, SecretBackendVault, SecretBackendDummy)
+	}
+	if s.Vault == nil {
+		return nil
+	}
+	if s.Vault.CACert != "" {
+		s.Vault.CACert = resolvePath(folder, s.Vault.CACert)
+	}
+	auth := s.Vault.Auth
+	if auth == nil {
+		return nil
+	}
+	switch auth.Method {
+	case "approle", "kubernetes", "userpass", "token":
+	default:
+		return fmt.Errorf("unknown vault auth method %q, use approle, kubernetes, userpass or token", auth.Method)
+	}
+	for _, file := range []*string{&auth.RoleIDFile, &auth.SecretIDFile, &auth.JWTFile, &auth.PasswordFile} {
+		if *file != "" {
+			*file = resolvePath(folder, *file)
+		}
+	}
+	return nil
+}
+
+func resolveDataFiles(folder string, dataFiles []string) error {
+	for i, dataFile := range dataFiles {
+		if _, ok := getDataReader(dataFile); !ok {
+			return fmt.Errorf("unknown data file type %q", dataFile)
+		}
+		dataFiles[i] = resolvePath(folder, dataFile)
+	}
+	return nil
+}
+
+func resolvePath(folder, p string) string {
+	if filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(folder, p)
+}
+
+// TargetNames are sorted
+func (p *Project) TargetNames() (names []string) {
+	for name := range p.Targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetTarget returns an error for unknown targets
+func (p *Project) GetTarget(name string) (*ProjectTarget, error) {
+	target, ok := p.Targets[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown target %q, there are %q", name, p.TargetNames())
+	}
+	return target, nil
+}
+
+// ApplyProfile layers the profile of the project and the profiles of the targets with the given name on top of the
+// targets, a profile, that is not defined anywhere, is an error
+func (p *Project) ApplyProfile(name string) error {
+	profile, found := p.Profiles[name]
+	for _, target := range p.Targets {
+		target.apply(profile)
+		if targetProfile, ok := target.Profiles[name]; ok {
+			target.apply(targetProfile)
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("unknown profile %q, there are %q", name, p.ProfileNames())
+	}
+	return nil
+}
+
+// ProfileNames of the project and all targets, sorted
+func (p *Project) ProfileNames() (names []string) {
+	known := map[string]bool{}
+	add := func(profiles map[string]*ProjectProfile) {
+		for name := range profiles {
+			if !known[name] {
+				known[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	add(p.Profiles)
+	for _, target := range p.Targets {
+		add(target.Profiles)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (t *ProjectTarget) apply(profile *ProjectProfile) {
+	if profile == nil {
+		return
+	}
+	t.Data = append(append([]string{}, t.Data...), profile.Data...)
+	if len(profile.Set) > 0 {
+		set := map[string]interface{}{}
+		for path, value := range t.Set {
+			set[path] = value
+		}
+		for path, value := range profile.Set {
+			set[path] = value
+		}
+		t.Set = set
+	}
+	if profile.Target != "" {
+		t.Target = profile.Target
+	}
+	if profile.Secrets != nil {
+		t.Secrets = profile.Secrets
+	}
+}
+
+// Args for building the target, overrides are set in the order of their paths
+func (t *ProjectTarget) Args() *Args {
+	args := &Args{
+		DataFiles:     t.Data,
+		SourceFolders: t.Sources,
+		TargetFolder:  t.Target,
+		ListMerge:     t.ListMerge,
+		ListMergeKey:  t.ListMergeKey,
+		EnvPrefix:     t.EnvPrefix,
+		MissingKey:    t.MissingKey,
+		Validate:      t.Validate,
+	}
+	paths := make([]string, 0, len(t.Set))
+	for p := range t.Set {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		args.Overrides = append(args.Overrides, DataOverride{Path: p, Value: normalizeData(t.Set[p])})
+	}
+	return args
+}
+
+// WriteOptions for writing the target
+func (t *ProjectTarget) WriteOptions() WriteOptions {
+	keep := t.Keep
+	if keep == 0 {
+		keep = 1
+	}
+	return WriteOptions{
+		Prune:     t.Prune,
+		Protected: t.Protect,
+		Atomic:    t.Atomic,
+		Keep:      keep,
+	}
+}
+
+// Apply configures the backend and the vault client for reading secrets and forgets all secrets, that have been read
+// before. The settings replace the ones of the last Apply, the environment of the process is not changed.
+func (s *SecretSettings) Apply() error {
+	if s != nil && s.Backend == SecretBackendDummy {
+		setSecretReaders(dummySecret, dummySecretMap)
+		return nil
+	}
+	setSecretReaders(readSecret, readSecretMap)
+	if s == nil || s.Vault == nil {
+		vault.SetDefaultEnv(nil)
+		return nil
+	}
+	env := map[string]string{
+		"VAULT_ADDR":      s.Vault.Address,
+		"VAULT_NAMESPACE": s.Vault.Namespace,
+		"VAULT_CACERT":    s.Vault.CACert,
+	}
+	if auth := s.Vault.Auth; auth != nil {
+		env["VAULT_AUTH_METHOD"] = auth.Method
+		env["VAULT_AUTH_MOUNT"] = auth.Mount
+		env["VAULT_ROLE_ID"] = auth.RoleID
+		env["VAULT_ROLE_ID_FILE"] = auth.RoleIDFile
+		env["VAULT_SECRET_ID_FILE"] = auth.SecretIDFile
+		env["VAULT_K8S_ROLE"] = auth.Role
+		env["VAULT_K8S_JWT_FILE"] = auth.JWTFile
+		env["VAULT_USERNAME"] = auth.Username
+		env["VAULT_PASSWORD_FILE"] = auth.PasswordFile
+	}
+	if s.Vault.SkipVerify {
+		env["VAULT_SKIP_VERIFY"] = strconv.FormatBool(true)
+	}
+	// unset settings are taken from the environment
+	for name, value := range env {
+		if value == "" {
+			delete(env, name)
+		}
+	}
+	vault.SetDefaultEnv(env)
+	return nil
+}
+
+// dummySecret checks the syntax of the key, but does not read anything
+func dummySecret(key string) (string, error) {
+	err := validateSecretKey(key)
+	if err != nil {
+		return "", err
+	}
+	return string(SecretBackendDummy) + ":" + key, nil
+}
+
+// dummySecretMap checks, that the secret could be read as a whole, but returns an empty map
+func dummySecretMap(key string) (map[string]interface{}, error) {
+	err := validateSecretMapKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{}, nil
+}
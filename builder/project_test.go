@@ -0,0 +1,267 @@
+package builder
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/foomo/config-bob/vault"
+)
+
+func TestLoadProject(t *testing.T) {
+	folder := getTestFolder(t, map[string]string{
+		DefaultProjectFile: `targets:
+  prod:
+    sources: [templates]
+    data: [data/common.yaml, data/prod.yaml]
+    target: /var/www/conf
+    set:
+      server.port: 443
+      name: prod
+    prune: true
+    protect: [certs]
+    secrets:
+      vault:
+        address: https://vault.example.com
+        caCert: ca.pem
+        auth:
+          method: approle
+          roleID: bob
+          secretIDFile: secrets/secret-id
+  dev:
+    sources: [templates]
+    target: build/dev
+    missingKey: report
+    keep: 3
+`,
+	})
+	project, err := LoadProject(filepath.Join(folder, DefaultProjectFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := project.TargetNames(); !reflect.DeepEqual(names, []string{"dev", "prod"}) {
+		t.Errorf("TargetNames() = %v", names)
+	}
+
+	prod, err := project.GetTarget("prod")
+	panicOnErr(err)
+	wantArgs := &Args{
+		SourceFolders: []string{filepath.Join(folder, "templates")},
+		DataFiles:     []string{filepath.Join(folder, "data", "common.yaml"), filepath.Join(folder, "data", "prod.yaml")},
+		TargetFolder:  "/var/www/conf",
+		Overrides:     []DataOverride{{Path: "name", Value: "prod"}, {Path: "server.port", Value: 443}},
+	}
+	if args := prod.Args(); !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("Args() = %v, want %v", args, wantArgs)
+	}
+	if options := prod.WriteOptions(); !reflect.DeepEqual(options, WriteOptions{Prune: true, Protected: []string{"certs"}, Keep: 1}) {
+		t.Errorf("WriteOptions() = %v", options)
+	}
+	if caCert := prod.Secrets.Vault.CACert; caCert != filepath.Join(folder, "ca.pem") {
+		t.Errorf("LoadProject() caCert = %q", caCert)
+	}
+	wantAuth := &VaultAuthSettings{Method: "approle", RoleID: "bob", SecretIDFile: filepath.Join(folder, "secrets", "secret-id")}
+	if !reflect.DeepEqual(prod.Secrets.Vault.Auth, wantAuth) {
+		t.Errorf("LoadProject() auth = %v, want %v", prod.Secrets.Vault.Auth, wantAuth)
+	}
+
+	dev, err := project.GetTarget("dev")
+	panicOnErr(err)
+	if args := dev.Args(); args.TargetFolder != filepath.Join(folder, "build", "dev") || args.MissingKey != MissingKeyReport {
+		t.Errorf("Args() = %v", args)
+	}
+	if keep := dev.WriteOptions().Keep; keep != 3 {
+		t.Errorf("WriteOptions() Keep = %d", keep)
+	}
+
+	if _, err = project.GetTarget("staging"); err == nil {
+		t.Error("GetTarget() expected an error for an unknown target")
+	}
+}
+
+func TestLoadProjectErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+	}{
+		{"unknown key", "targets:\n  prod:\n    sources: [a]\n    target: b\n    source: [c]\n"},
+		{"no targets", "targets: {}\n"},
+		{"no sources", "targets:\n  prod:\n    target: b\n"},
+		{"no target", "targets:\n  prod:\n    sources: [a]\n"},
+		{"unknown data type", "targets:\n  prod:\n    sources: [a]\n    data: [data.txt]\n    target: b\n"},
+		{"unknown auth", "targets:\n  prod:\n    sources: [a]\n    target: b\n    secrets:\n      vault:\n        auth:\n          method: github\n"},
+		{"unknown backend", "targets:\n  web:\n    sources: [a]\n    target: b\nprofiles:\n  dev:\n    secrets:\n      backend: keychain\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			folder := getTestFolder(t, map[string]string{DefaultProjectFile: tt.contents})
+			if _, err := LoadProject(filepath.Join(folder, DefaultProjectFile)); err == nil {
+				t.Error("LoadProject() expected an error")
+			}
+		})
+	}
+}
+
+func TestProjectBuild(t *testing.T) {
+	folder := getTestFolder(t, map[string]string{
+		"templates/app.conf": "{{ .name }}:{{ .port }}",
+		"data.yaml":          "name: app\nport: 80\n",
+		DefaultProjectFile:   "targets:\n  prod:\n    sources: [templates]\n    data: [data.yaml]\n    target: out\n    set:\n      port: 443\n",
+	})
+	project, err := LoadProject(filepath.Join(folder, DefaultProjectFile))
+	panicOnErr(err)
+	target, err := project.GetTarget("prod")
+	panicOnErr(err)
+	result, err := Build(target.Args())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(result.Files["app.conf"].bytes); got != "app:443" {
+		t.Errorf("Build() = %q, want %q", got, "app:443")
+	}
+}
+
+func TestSecretSettingsApply(t *testing.T) {
+	t.Setenv("VAULT_ADDR", "https://env.example.com")
+	for _, name := range []string{"VAULT_NAMESPACE", "VAULT_CACERT", "VAULT_SKIP_VERIFY", "VAULT_AUTH_METHOD"} {
+		t.Setenv(name, "")
+	}
+	t.Cleanup(func() {
+		vault.SetDefaultEnv(nil)
+	})
+	settings := &SecretSettings{Vault: &VaultSettings{Address: "https://vault.example.com", Namespace: "team", SkipVerify: true}}
+	// steps run in order like the targets of a project
+	tests := []struct {
+		name           string
+		settings       *SecretSettings
+		wantAddress    string
+		wantNamespace  string
+		wantSkipVerify bool
+	}{
+		{"vault settings", settings, "https://vault.example.com", "team", true},
+		{"settings of the last target are not kept", &SecretSettings{Vault: &VaultSettings{}}, "https://env.example.com", "", false},
+		{"vault settings again", settings, "https://vault.example.com", "team", true},
+		{"no settings", nil, "https://env.example.com", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			panicOnErr(tt.settings.Apply())
+			client, err := vault.DefaultClient()
+			if err != nil {
+				t.Fatal(err)
+			}
+			skipVerify := client.HTTP.Transport.(*http.Transport).TLSClientConfig.InsecureSkipVerify
+			if client.Address != tt.wantAddress || client.Namespace != tt.wantNamespace || skipVerify != tt.wantSkipVerify {
+				t.Errorf("Apply() client = %q %q %v, want %q %q %v", client.Address, client.Namespace, skipVerify, tt.wantAddress, tt.wantNamespace, tt.wantSkipVerify)
+			}
+			if got := os.Getenv("VAULT_ADDR"); got != "https://env.example.com" {
+				t.Errorf("Apply() changed VAULT_ADDR to %q", got)
+			}
+		})
+	}
+}
+
+func TestProjectProfiles(t *testing.T) {
+	folder := getTestFolder(t, map[string]string{
+		DefaultProjectFile: `targets:
+  web:
+    sources: [templates]
+    data: [data/base.yaml]
+    target: build/web
+    set:
+      name: web
+      port: 80
+    secrets:
+      vault:
+        address: https://vault.example.com
+    profiles:
+      prod:
+        target: /etc/web
+  api:
+    sources: [templates]
+    target: build/api
+profiles:
+  dev:
+    data: [data/dev.yaml]
+    set:
+      port: 8080
+    secrets:
+      backend: dummy
+  prod:
+    data: [data/prod.yaml]
+`,
+	})
+	load := func(profile string) *Project {
+		project, err := LoadProject(filepath.Join(folder, DefaultProjectFile))
+		panicOnErr(err)
+		panicOnErr(project.ApplyProfile(profile))
+		return project
+	}
+
+	dev := load("dev")
+	web := dev.Targets["web"]
+	if want := []string{filepath.Join(folder, "data", "base.yaml"), filepath.Join(folder, "data", "dev.yaml")}; !reflect.DeepEqual(web.Data, want) {
+		t.Errorf("ApplyProfile() data = %v, want %v", web.Data, want)
+	}
+	if want := []DataOverride{{Path: "name", Value: "web"}, {Path: "port", Value: 8080}}; !reflect.DeepEqual(web.Args().Overrides, want) {
+		t.Errorf("ApplyProfile() overrides = %v, want %v", web.Args().Overrides, want)
+	}
+	if web.Target != filepath.Join(folder, "build", "web") || web.Secrets.Backend != SecretBackendDummy {
+		t.Errorf("ApplyProfile() target = %q, backend = %q", web.Target, web.Secrets.Backend)
+	}
+	if want := []string{filepath.Join(folder, "data", "dev.yaml")}; !reflect.DeepEqual(dev.Targets["api"].Data, want) {
+		t.Errorf("ApplyProfile() data of api = %v, want %v", dev.Targets["api"].Data, want)
+	}
+
+	prod := load("prod")
+	if target := prod.Targets["web"].Target; target != "/etc/web" {
+		t.Errorf("ApplyProfile() target of web = %q", target)
+	}
+	if target := prod.Targets["api"].Target; target != filepath.Join(folder, "build", "api") {
+		t.Errorf("ApplyProfile() target of api = %q", target)
+	}
+	if address := prod.Targets["web"].Secrets.Vault.Address; address != "https://vault.example.com" {
+		t.Errorf("ApplyProfile() vault address = %q", address)
+	}
+
+	project, err := LoadProject(filepath.Join(folder, DefaultProjectFile))
+	panicOnErr(err)
+	if names := project.ProfileNames(); !reflect.DeepEqual(names, []string{"dev", "prod"}) {
+		t.Errorf("ProfileNames() = %v", names)
+	}
+	if err = project.ApplyProfile("staging"); err == nil {
+		t.Error("ApplyProfile() expected an error for an unknown profile")
+	}
+}
+
+func TestDummySecrets(t *testing.T) {
+	settings := &SecretSettings{Backend: SecretBackendDummy}
+	panicOnErr(settings.Apply())
+	defer func() {
+		panicOnErr((*SecretSettings)(nil).Apply())
+	}()
+	tests := []struct {
+		name     string
+		template string
+		want     string
+		wantErr  bool
+	}{
+		{"secret", `password={{ secret "app/db.password" }}`, "password=dummy:app/db.password", false},
+		{"secret map", `{{ range secretMap "app/db" }}x{{ end }}`, "", false},
+		{"invalid key", `{{ secret "app/db" }}`, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := getTestFolder(t, map[string]string{"app.conf": tt.template})
+			result, err := Build(&Args{SourceFolders: []string{source}})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Build() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && string(result.Files["app.conf"].bytes) != tt.want {
+				t.Errorf("Build() = %q, want %q", result.Files["app.conf"].bytes, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package builder
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Prune removes all files and empty folders from the target folder, which are not part of the processing result.
+// Protected paths are relative to the target folder, may contain glob patterns and everything below a protected
+// folder survives as well
+func Prune(targetFolder string, result *ProcessingResult, protected []string) (removed []string, err error) {
+	fmt.Println(line)
+	fmt.Println("pruning target folder:")
+	fmt.Println(line)
+	staleFiles, staleFolders, err := getStale(targetFolder, result, protected)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range staleFiles {
+		removed = append(removed, file)
+		fmt.Println(len(removed), "removing file  ", path.Join(targetFolder, file))
+		err := os.Remove(path.Join(targetFolder, file))
+		if err != nil {
+			return removed, err
+		}
+	}
+	for _, folder := range staleFolders {
+		removed = append(removed, folder+"/")
+		fmt.Println(len(removed), "removing folder", path.Join(targetFolder, folder))
+		err := os.Remove(path.Join(targetFolder, folder))
+		if err != nil {
+			return removed, err
+		}
+	}
+	if len(removed) == 0 {
+		fmt.Println("nothing to prune")
+	}
+	return removed, nil
+}
+
+// getStale lists files, that are not part of the result and folders, that would be empty once those are gone.
+// Folders are sorted deepest first, so that they can be removed in order.
+func getStale(targetFolder string, result *ProcessingResult, protected []string) (files []string, folders []string, err error) {
+	if _, err := os.Stat(targetFolder); os.IsNotExist(err) {
+		return nil, nil, nil
+	}
+	keep := map[string]bool{}
+	keepParents := func(p string) {
+		for p = path.Dir(p); p != "." && p != "/"; p = path.Dir(p) {
+			keep[p] = true
+		}
+	}
+	for _, folder := range result.Folders {
+		keep[folder] = true
+		keepParents(folder)
+	}
+	for file := range result.Files {
+		keepParents(file)
+	}
+
+	var candidateFolders []string
+	// the files of an atomically written target folder are in the generation it links to
+	targetFolder = getCurrentFolder(targetFolder)
+	err = filepath.Walk(targetFolder, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relativePath, err := filepath.Rel(targetFolder, p)
+		if err != nil || relativePath == "." {
+			return err
+		}
+		relativePath = filepath.ToSlash(relativePath)
+		if pathIsProtected(relativePath, protected) {
+			keepParents(relativePath)
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			candidateFolders = append(candidateFolders, relativePath)
+			return nil
+		}
+		if _, ok := result.Files[relativePath]; ok {
+			return nil
+		}
+		files = append(files, relativePath)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, folder := range candidateFolders {
+		if !keep[folder] {
+			folders = append(folders, folder)
+		}
+	}
+	sort.Strings(files)
+	sort.Sort(sort.Reverse(sort.StringSlice(folders)))
+	return files, folders, nil
+}
+
+func pathIsProtected(p string, protected []string) bool {
+	for _, protectedPath := range protected {
+		protectedPath = strings.Trim(path.Clean(filepath.ToSlash(protectedPath)), "/")
+		if p == protectedPath || strings.HasPrefix(p, protectedPath+"/") {
+			return true
+		}
+		if matched, _ := path.Match(protectedPath, p); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package builder
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPrune(t *testing.T) {
+	targetFolder := getTestFolder(t, map[string]string{
+		"keep.txt":             "keep",
+		"stale.txt":            "stale",
+		"httpd/test.conf":      "test",
+		"httpd/old.conf":       "old",
+		"old/a/b.txt":          "b",
+		"local/hand-made.conf": "mine",
+		"certs/local.pem":      "pem",
+		"notes.bak":            "bak",
+	})
+	panicOnErr(os.MkdirAll(filepath.Join(targetFolder, "empty"), 0755))
+	panicOnErr(os.MkdirAll(filepath.Join(targetFolder, "empty-but-built"), 0755))
+	result := getTestResult(t, map[string]string{
+		"keep.txt":        "keep",
+		"httpd/test.conf": "test",
+	}, "httpd", "empty-but-built")
+	protected := []string{"local", "certs/*.pem", "*.bak"}
+	expected := []string{"httpd/old.conf", "old/a/b.txt", "stale.txt", "old/a/", "old/", "empty/"}
+
+	plan, err := GetPlan(targetFolder, result)
+	panicOnErr(err)
+	panicOnErr(plan.IncludePrune(result, protected))
+	if !reflect.DeepEqual(plan.Removals, expected) {
+		t.Errorf("IncludePrune() Removals = %v, want %v", plan.Removals, expected)
+	}
+
+	removed, err := Prune(targetFolder, result, protected)
+	if err != nil {
+		t.Fatal("Prune() error", err)
+	}
+	if !reflect.DeepEqual(removed, expected) {
+		t.Errorf("Prune() = %v, want %v", removed, expected)
+	}
+	tests := []struct {
+		path    string
+		removed bool
+	}{
+		{"keep.txt", false},
+		{"httpd/test.conf", false},
+		{"local/hand-made.conf", false},
+		{"certs/local.pem", false},
+		{"notes.bak", false},
+		{"empty-but-built", false},
+		{"stale.txt", true},
+		{"httpd/old.conf", true},
+		{"old", true},
+		{"empty", true},
+	}
+	for _, tt := range tests {
+		_, err := os.Stat(filepath.Join(targetFolder, tt.path))
+		if os.IsNotExist(err) != tt.removed {
+			t.Errorf("Prune() removed %q = %v, want %v", tt.path, os.IsNotExist(err), tt.removed)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/foomo/config-bob/vault"
+)
+
+// SecretProvider reads the secrets, that the secret template function references with the scheme of the provider
+// like scheme://reference
+type SecretProvider interface {
+	// ReadSecret gets the reference without the scheme
+	ReadSecret(ref string) (string, error)
+}
+
+// SecretRefValidator is implemented by providers, that can check the syntax of references without reading them, lint
+// and the dummy secret backend use it
+type SecretRefValidator interface {
+	ValidateSecretRef(ref string) error
+}
+
+// SecretMapProvider is implemented by providers, that can read whole secrets for the secretMap template function
+type SecretMapProvider interface {
+	ReadSecretMap(ref string) (map[string]interface{}, error)
+}
+
+// SecretProviderFunc turns a function into a SecretProvider
+type SecretProviderFunc func(ref string) (string, error)
+
+// ReadSecret calls f
+func (f SecretProviderFunc) ReadSecret(ref string) (string, error) {
+	return f(ref)
+}
+
+// DefaultSecretScheme is used for secret keys without a scheme
+const DefaultSecretScheme = "vault"
+
+const secretSchemeSeparator = "://"
+
+var (
+	secretProvidersLock = &sync.RWMutex{}
+	secretProviders     = map[string]SecretProvider{
+		"vault": vaultSecretProvider{},
+		"op":    onePasswordSecretProvider{},
+		"file":  fileSecretProvider{},
+		"env":   envSecretProvider{},
+	}
+)
+
+// RegisterSecretProvider registers a provider for secret keys like scheme://reference, an existing provider for the
+// same scheme is replaced
+func RegisterSecretProvider(scheme string, provider SecretProvider) {
+	secretProvidersLock.Lock()
+	defer secretProvidersLock.Unlock()
+	secretProviders[strings.ToLower(scheme)] = provider
+}
+
+// SecretSchemes lists all schemes, that there is a provider for
+func SecretSchemes() (schemes []string) {
+	secretProvidersLock.RLock()
+	defer secretProvidersLock.RUnlock()
+	for scheme := range secretProviders {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// getSecretProvider finds the provider for a secret key and returns the reference without the scheme
+func getSecretProvider(key string) (provider SecretProvider, ref string, err error) {
+	scheme, ref := DefaultSecretScheme, key
+	if i := strings.Index(key, secretSchemeSeparator); i >= 0 {
+		scheme, ref = strings.ToLower(key[:i]), key[i+len(secretSchemeSeparator):]
+	}
+	secretProvidersLock.RLock()
+	provider, ok := secretProviders[scheme]
+	secretProvidersLock.RUnlock()
+	if !ok {
+		return nil, "", fmt.Errorf("unknown secret scheme %q in %q, there are %q", scheme, key, SecretSchemes())
+	}
+	return provider, ref, nil
+}
+
+// readSecret reads a secret from the provider of its scheme
+func readSecret(key string) (string, error) {
+	provider, ref, err := getSecretProvider(key)
+	if err != nil {
+		return "", err
+	}
+	return provider.ReadSecret(ref)
+}
+
+// readSecretMap reads a whole secret from the provider of its scheme
+func readSecretMap(key string) (map[string]interface{}, error) {
+	provider, ref, err := getSecretProvider(key)
+	if err != nil {
+		return nil, err
+	}
+	mapProvider, ok := provider.(SecretMapProvider)
+	if !ok {
+		return nil, fmt.Errorf("secret %q can not be read as a whole", key)
+	}
+	return mapProvider.ReadSecretMap(ref)
+}
+
+// validateSecretMapKey checks, that there is a provider for the key, that can read whole secrets and that it accepts
+// the reference
+func validateSecretMapKey(key string) error {
+	provider, ref, err := getSecretProvider(key)
+	if err != nil {
+		return err
+	}
+	if _, ok := provider.(SecretMapProvider); !ok {
+		return fmt.Errorf("secret %q can not be read as a whole", key)
+	}
+	if validator, ok := provider.(interface{ ValidateSecretMapRef(ref string) error }); ok {
+		return validator.ValidateSecretMapRef(ref)
+	}
+	return nil
+}
+
+// validateSecretKey checks, that there is a provider for the key and that it accepts the reference
+func validateSecretKey(key string) error {
+	provider, ref, err := getSecretProvider(key)
+	if err != nil {
+		return err
+	}
+	if validator, ok := provider.(SecretRefValidator); ok {
+		return validator.ValidateSecretRef(ref)
+	}
+	return nil
+}
+
+// vaultSecretProvider reads path/to/secret.prop from vault, path/to/secret.prop@3 reads version 3 of a secret in a kv
+// version 2 mount
+type vaultSecretProvider struct{}
+
+// splitVaultRef splits path/to/secret.prop@version, the version is 0 without @
+func splitVaultRef(ref string) (path, prop string, version int, err error) {
+	parts := strings.Split(ref, ".")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", 0, fmt.Errorf("secret key %q has to be like \"path/to/secret.prop\"", ref)
+	}
+	path, prop = parts[0], parts[1]
+	if i := strings.LastIndex(prop, "@"); i >= 0 {
+		version, err = strconv.Atoi(prop[i+1:])
+		if err != nil || version < 1 || i == 0 {
+			return "", "", 0, fmt.Errorf("secret key %q has to be like \"path/to/secret.prop@version\" with a version > 0", ref)
+		}
+		prop = prop[:i]
+	}
+	return path, prop, version, nil
+}
+
+func (vaultSecretProvider) ValidateSecretRef(ref string) error {
+	_, _, _, err := splitVaultRef(ref)
+	return err
+}
+
+func (vaultSecretProvider) ReadSecret(ref string) (v string, err error) {
+	path, prop, version, err := splitVaultRef(ref)
+	if err != nil {
+		v = "syntax error key must be \"path/to/secret.prop\""
+		return v, errors.New(v)
+	}
+	secretData, err := vault.ReadVersion(path, version)
+	if err != nil {
+		v = "secret retrieval error: " + err.Error()
+		return v, errors.New(v)
+	}
+	s, ok := secretData[prop]
+	if !ok {
+		return "<prop not found on secret>", errors.New("property \"" + prop + "\" is not set for secret " + path + " " + fmt.Sprint(secretData))
+	}
+	return vault.ValueString(s), nil
+}
+
+// splitVaultMapRef splits path/to/secret@version, the version is 0 without @
+func splitVaultMapRef(ref string) (path string, version int, err error) {
+	path = ref
+	if i := strings.LastIndex(ref, "@"); i >= 0 {
+		version, err = strconv.Atoi(ref[i+1:])
+		if err != nil || version < 1 {
+			return "", 0, fmt.Errorf("secret key %q has to be like \"path/to/secret@version\" with a version > 0", ref)
+		}
+		path = ref[:i]
+	}
+	if path == "" || strings.Contains(path, ".") {
+		return "", 0, fmt.Errorf("secret key %q has to be like \"path/to/secret\"", ref)
+	}
+	return path, version, nil
+}
+
+func (vaultSecretProvider) ValidateSecretMapRef(ref string) error {
+	_, _, err := splitVaultMapRef(ref)
+	return err
+}
+
+func (vaultSecretProvider) ReadSecretMap(ref string) (map[string]interface{}, error) {
+	path, version, err := splitVaultMapRef(ref)
+	if err != nil {
+		return nil, err
+	}
+	secretData, err := vault.ReadVersion(path, version)
+	if err != nil {
+		return nil, errors.New("secret retrieval error: " + err.Error())
+	}
+	return secretData, nil
+}
+
+// onePasswordSecretProvider reads item/field from 1Password, the item is everything before the last slash
+type onePasswordSecretProvider struct{}
+
+func (onePasswordSecretProvider) ValidateSecretRef(ref string) error {
+	i := strings.LastIndex(ref, "/")
+	if i <= 0 || i == len(ref)-1 {
+		return fmt.Errorf("secret key %q has to be like \"op://item/field\"", ref)
+	}
+	return nil
+}
+
+func (p onePasswordSecretProvider) ReadSecret(ref string) (string, error) {
+	err := p.ValidateSecretRef(ref)
+	if err != nil {
+		return "", err
+	}
+	i := strings.LastIndex(ref, "/")
+	return onePassword(ref[:i], ref[i+1:])
+}
+
+// fileSecretProvider reads the contents of a file without trailing line breaks
+type fileSecretProvider struct{}
+
+func (fileSecretProvider) ValidateSecretRef(ref string) error {
+	if ref == "" {
+		return errors.New("secret key has to be like \"file://path/to/file\"")
+	}
+	return nil
+}
+
+func (fileSecretProvider) ReadSecret(ref string) (string, error) {
+	secretBytes, err := ioutil.ReadFile(ref)
+	if err != nil {
+		return "", errors.New("secret retrieval error: " + err.Error())
+	}
+	return strings.TrimRight(string(secretBytes), "\r\n"), nil
+}
+
+// envSecretProvider reads environment variables, that must not be empty
+type envSecretProvider struct{}
+
+func (envSecretProvider) ValidateSecretRef(ref string) error {
+	if ref == "" {
+		return errors.New("secret key has to be like \"env://NAME\"")
+	}
+	return nil
+}
+
+func (envSecretProvider) ReadSecret(ref string) (string, error) {
+	value := os.Getenv(ref)
+	if value == "" {
+		return "", fmt.Errorf("env variable %q was empty", ref)
+	}
+	return value, nil
+}
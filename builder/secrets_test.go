@@ -0,0 +1,199 @@
+package builder
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/foomo/config-bob/vault"
+)
+
+// useTestVault makes the secret template functions talk to handler instead of vault
+func useTestVault(t *testing.T, handler http.HandlerFunc) {
+	server := httptest.NewServer(handler)
+	dummy := vault.Dummy
+	vault.Dummy = false
+	vault.SetDefaultClient(&vault.Client{Address: server.URL})
+	clearSecretCache()
+	t.Cleanup(func() {
+		server.Close()
+		vault.Dummy = dummy
+		vault.SetDefaultClient(nil)
+		clearSecretCache()
+	})
+}
+
+type testSecretProvider map[string]string
+
+func (p testSecretProvider) ReadSecret(ref string) (string, error) {
+	value, ok := p[ref]
+	if !ok {
+		return "", errors.New("not found")
+	}
+	return value, nil
+}
+
+func TestSecretProviders(t *testing.T) {
+	clearSecretCache()
+	RegisterSecretProvider("test", testSecretProvider{"db/password": "s3cret"})
+	defer func() {
+		secretProvidersLock.Lock()
+		delete(secretProviders, "test")
+		secretProvidersLock.Unlock()
+	}()
+	found := false
+	for _, scheme := range SecretSchemes() {
+		found = found || scheme == "test"
+	}
+	if !found {
+		t.Errorf("SecretSchemes() = %v, want test", SecretSchemes())
+	}
+
+	secrets := getTestFolder(t, map[string]string{"token": "file-token\n"})
+	t.Setenv("BOB_TEST_SECRET", "env-secret")
+	tests := []struct {
+		key     string
+		want    string
+		wantErr bool
+	}{
+		{"test://db/password", "s3cret", false},
+		{"test://db/user", "", true},
+		{"file://" + filepath.Join(secrets, "token"), "file-token", false},
+		{"file://" + filepath.Join(secrets, "missing"), "", true},
+		{"env://BOB_TEST_SECRET", "env-secret", false},
+		{"env://BOB_TEST_UNSET", "", true},
+		{"unknown://a", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			source := getTestFolder(t, map[string]string{"app.conf": `{{ secret "` + tt.key + `" }}`})
+			result, err := Build(&Args{SourceFolders: []string{source}})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Build() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && string(result.Files["app.conf"].bytes) != tt.want {
+				t.Errorf("Build() = %q, want %q", result.Files["app.conf"].bytes, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateSecretKey(t *testing.T) {
+	tests := []struct {
+		key   string
+		valid bool
+	}{
+		{"path/to/secret.prop", true},
+		{"vault://path/to/secret.prop", true},
+		{"path/to/secret", false},
+		{"path/to/secret.prop@3", true},
+		{"path/to/secret.prop@0", false},
+		{"path/to/secret.prop@latest", false},
+		{"path/to/secret.@3", false},
+		{"op://my-item/password", true},
+		{"op://https://x.com/item/pw", true},
+		{"op://item", false},
+		{"op://item/", false},
+		{"file://secrets/token", true},
+		{"file://", false},
+		{"env://TOKEN", true},
+		{"env://", false},
+		{"nope://a", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if err := validateSecretKey(tt.key); (err == nil) != tt.valid {
+				t.Errorf("validateSecretKey() error = %v, valid %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestValidateSecretMapKey(t *testing.T) {
+	tests := []struct {
+		key   string
+		valid bool
+	}{
+		{"secret/app", true},
+		{"secret/app@2", true},
+		{"secret/app@latest", false},
+		{"env://HOME", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if err := validateSecretMapKey(tt.key); (err == nil) != tt.valid {
+				t.Errorf("validateSecretMapKey() error = %v, valid %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestVaultSecretVersions(t *testing.T) {
+	useTestVault(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/sys/internal/ui/mounts/kv/app/db":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{
+				"path": "kv/", "type": "kv", "options": map[string]string{"version": "2"},
+			}})
+		case "/v1/kv/data/app/db":
+			password := "latest"
+			if r.URL.Query().Get("version") == "2" {
+				password = "second"
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{
+				"data": map[string]string{"password": password},
+			}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	source := getTestFolder(t, map[string]string{
+		"app.conf": `{{ secret "kv/app/db.password" }} {{ secret "kv/app/db.password@2" }}`,
+	})
+	result, err := Build(&Args{SourceFolders: []string{source}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(result.Files["app.conf"].bytes); got != "latest second" {
+		t.Errorf("Build() = %q, want %q", got, "latest second")
+	}
+}
+
+func TestVaultSecretMap(t *testing.T) {
+	useTestVault(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/secret/app" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"data": {"port": 8080, "debug": false, "hosts": ["a", "b"], "db": {"user": "app"}}}`))
+	})
+	tests := []struct {
+		name     string
+		template string
+		want     string
+		wantErr  bool
+	}{
+		{"typed values", `{{ secret "secret/app.port" }} {{ secret "secret/app.hosts" }} {{ secret "secret/app.db" }}`, `8080 ["a","b"] {"user":"app"}`, false},
+		{"range", `{{ range $key, $value := secretMap "secret/app" }}{{ $key }}={{ json $value }} {{ end }}`, `db={"user":"app"} debug=false hosts=["a","b"] port=8080 `, false},
+		{"with", `{{ with secretMap "secret/app" }}{{ .port }} {{ index .hosts 1 }} {{ .db.user }}{{ end }}`, "8080 b app", false},
+		{"property", `{{ secretMap "secret/app.port" }}`, "", true},
+		{"no vault", `{{ secretMap "env://HOME" }}`, "", true},
+		{"missing", `{{ secretMap "secret/missing" }}`, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := getTestFolder(t, map[string]string{"app.conf": tt.template})
+			result, err := Build(&Args{SourceFolders: []string{source}})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Build() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && string(result.Files["app.conf"].bytes) != tt.want {
+				t.Errorf("Build() = %q, want %q", result.Files["app.conf"].bytes, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// SkippedFile is a file, that has not been rendered, because its template called skip
+type SkippedFile struct {
+	// Source is the path of the template
+	Source string
+	Reason string
+}
+
+type skipError struct {
+	reason string
+}
+
+func (e *skipError) Error() string {
+	return "skipped: " + e.reason
+}
+
+// skip stops rendering a template and drops its file from the result
+func skip(reason ...interface{}) (string, error) {
+	return "", &skipError{reason: fmt.Sprint(reason...)}
+}
+
+func getSkipReason(err error) (reason string, ok bool) {
+	var e *skipError
+	if errors.As(err, &e) {
+		return e.reason, true
+	}
+	return "", false
+}
+
+func (p *ProcessingResult) printSkipped() {
+	if len(p.Skipped) == 0 {
+		return
+	}
+	var files []string
+	for file := range p.Skipped {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	fmt.Println(line)
+	fmt.Println("skipped files:")
+	fmt.Println(line)
+	for _, file := range files {
+		skipped := p.Skipped[file]
+		reason := skipped.Reason
+		if reason == "" {
+			reason = "no reason given"
+		}
+		fmt.Println("skipped    :", file, "from", skipped.Source, "-", reason)
+	}
+}
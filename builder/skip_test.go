@@ -0,0 +1,90 @@
+package builder
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSkip(t *testing.T) {
+	sourceA := getTestFolder(t, map[string]string{
+		"dev-only.conf":    `{{ if ne .env "dev" }}{{ skip "only needed in dev, env is " .env }}{{ end }}debug`,
+		"prod-only.conf":   `{{ if ne .env "prod" }}{{ skip }}{{ end }}tls`,
+		"overridden.conf":  "from a",
+		"dropped.conf":     "from a",
+		"partial.conf":     `{{ include "guard" . }}rendered`,
+		"_partials/guard":  `{{ if .guarded }}{{ skip "guarded" }}{{ end }}`,
+		"sub/skipped.conf": `{{ skip "nothing to see" }}`,
+	})
+	sourceB := getTestFolder(t, map[string]string{
+		"overridden.conf": "from b",
+		"dropped.conf":    `{{ skip "dropped by b" }}`,
+	})
+	result, err := Build(&Args{
+		SourceFolders: []string{sourceA, sourceB},
+		Overrides: []DataOverride{
+			{Path: "env", Value: "prod"},
+			{Path: "guarded", Value: true},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"prod-only.conf":  "tls",
+		"overridden.conf": "from b",
+	}
+	if got := getContents(result); !reflect.DeepEqual(got, want) {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+	wantSkipped := map[string]*SkippedFile{
+		"dev-only.conf":    {Source: filepath.Join(sourceA, "dev-only.conf"), Reason: "only needed in dev, env is prod"},
+		"partial.conf":     {Source: filepath.Join(sourceA, "partial.conf"), Reason: "guarded"},
+		"sub/skipped.conf": {Source: filepath.Join(sourceA, "sub/skipped.conf"), Reason: "nothing to see"},
+		"dropped.conf":     {Source: filepath.Join(sourceB, "dropped.conf"), Reason: "dropped by b"},
+	}
+	if !reflect.DeepEqual(result.Skipped, wantSkipped) {
+		t.Errorf("Build() Skipped = %v, want %v", result.Skipped, wantSkipped)
+	}
+	// the folder of a skipped file is still created
+	if want := []string{"sub"}; !reflect.DeepEqual(result.Folders, want) {
+		t.Errorf("Build() Folders = %v, want %v", result.Folders, want)
+	}
+}
+
+func TestWatcherSkip(t *testing.T) {
+	sourceFolder := getTestFolder(t, map[string]string{
+		"a.conf": "a",
+		"b.conf": "{{ skip }}",
+	})
+	w := &watcher{args: &Args{SourceFolders: []string{sourceFolder}}}
+	panicOnErr(w.build())
+	tests := []struct {
+		name        string
+		file        string
+		contents    string
+		want        map[string]string
+		wantSkipped int
+	}{
+		{"skipping", "a.conf", "{{ skip }}", map[string]string{}, 2},
+		{"no longer skipping", "b.conf", "b", map[string]string{"b.conf": "b"}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			touchTestFile(filepath.Join(sourceFolder, tt.file), tt.contents)
+			reason, err := w.update(false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := filepath.Join(sourceFolder, tt.file) + " changed, full build"; reason != want {
+				t.Errorf("update() reason = %q, want %q", reason, want)
+			}
+			if got := getContents(w.result); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("update() result = %v, want %v", got, tt.want)
+			}
+			if len(w.result.Skipped) != tt.wantSkipped {
+				t.Errorf("update() Skipped = %v, want %d", w.result.Skipped, tt.wantSkipped)
+			}
+		})
+	}
+}
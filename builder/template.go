@@ -20,9 +20,48 @@ import (
 var (
 	secretCacheSF   = singleflight.Group{}
 	secretCacheLock = &sync.RWMutex{}
-	secretCache     = map[string]string{}
+	secretCache     = map[string]interface{}{}
+	secretReader    = readSecret
+	secretMapReader = readSecretMap
 )
 
+func clearSecretCache() {
+	secretCacheLock.Lock()
+	secretCache = map[string]interface{}{}
+	secretCacheLock.Unlock()
+}
+
+// setSecretReaders replace the functions, that the secret and secretMap template functions read uncached secrets
+// with
+func setSecretReaders(reader func(key string) (string, error), mapReader func(key string) (map[string]interface{}, error)) {
+	secretCacheLock.Lock()
+	secretCache = map[string]interface{}{}
+	secretReader, secretMapReader = reader, mapReader
+	secretCacheLock.Unlock()
+}
+
+// cachedSecret reads every secret once until the cache is cleared, cacheKey has to be unique across the template
+// functions
+func cachedSecret(cacheKey string, read func() (interface{}, error)) (interface{}, error) {
+	secretCacheLock.RLock()
+	if value, ok := secretCache[cacheKey]; ok {
+		secretCacheLock.RUnlock()
+		return value, nil
+	}
+	secretCacheLock.RUnlock()
+	value, err, _ := secretCacheSF.Do(cacheKey, func() (interface{}, error) {
+		value, err := read()
+		if err != nil {
+			return nil, err
+		}
+		secretCacheLock.Lock()
+		secretCache[cacheKey] = value
+		secretCacheLock.Unlock()
+		return value, nil
+	})
+	return value, err
+}
+
 // TemplateFuncs knock yourself out - this is what builder user for templating
 var TemplateFuncs = template.FuncMap{
 	"substr": func(str string, ranger string) (v string, err error) {
@@ -108,31 +147,33 @@ var TemplateFuncs = template.FuncMap{
 	},
 	"secret": func(key string) (string, error) {
 		secretCacheLock.RLock()
-		if value, ok := secretCache[key]; ok {
-			secretCacheLock.RUnlock()
-			return value, nil
-		}
+		read := secretReader
 		secretCacheLock.RUnlock()
-		value, err, _ := secretCacheSF.Do(key, func() (interface{}, error) {
-			value, err := rawSecret(key)
-			if err != nil {
-				return nil, err
-			}
-			secretCacheLock.Lock()
-			secretCache[key] = value
-			secretCacheLock.Unlock()
-			return value, nil
+		value, err := cachedSecret("secret "+key, func() (interface{}, error) {
+			return read(key)
 		})
 		if err != nil {
 			return "", err
 		}
-
 		return value.(string), nil
 	},
+	"secretMap": func(key string) (map[string]interface{}, error) {
+		secretCacheLock.RLock()
+		read := secretMapReader
+		secretCacheLock.RUnlock()
+		value, err := cachedSecret("secretMap "+key, func() (interface{}, error) {
+			return read(key)
+		})
+		if err != nil {
+			return nil, err
+		}
+		return value.(map[string]interface{}), nil
+	},
 	"replace": replace,
 	"op":      onePassword,
 	"absPath": filepath.Abs,
 	"join":    join,
+	"skip":    skip,
 }
 
 func join(value interface{}, separator string) (string, error) {
@@ -0,0 +1,150 @@
+package builder
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"gopkg.in/yaml.v2"
+)
+
+// outputParsers parse rendered files by their extension into json compatible values
+var outputParsers = map[string]func(fileBytes []byte) (documents []interface{}, err error){
+	".json": parseJSONOutput,
+	".yml":  parseYAMLOutput,
+	".yaml": parseYAMLOutput,
+	".toml": parseTOMLOutput,
+}
+
+func parseJSONOutput(fileBytes []byte) (documents []interface{}, err error) {
+	var document interface{}
+	decoder := json.NewDecoder(bytes.NewReader(fileBytes))
+	decoder.UseNumber()
+	err = decoder.Decode(&document)
+	if err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, errors.New("unexpected data after the json value")
+	}
+	return []interface{}{document}, nil
+}
+
+// parseYAMLOutput returns every document of multi document files
+func parseYAMLOutput(fileBytes []byte) (documents []interface{}, err error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(fileBytes))
+	for {
+		var document interface{}
+		err = decoder.Decode(&document)
+		if err == io.EOF {
+			return documents, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, normalizeData(document))
+	}
+}
+
+func parseTOMLOutput(fileBytes []byte) (documents []interface{}, err error) {
+	document := map[string]interface{}{}
+	err = toml.Unmarshal(fileBytes, &document)
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{document}, nil
+}
+
+// Validate parses rendered json, yaml and toml files and validates files, that have json schemas mapped to them
+// in a .bobconfig
+func (p *ProcessingResult) Validate() error {
+	var (
+		errs     Errors
+		schemas  = map[string]*jsonschema.Schema{}
+		compiler = jsonschema.NewCompiler()
+		files    = make([]string, 0, len(p.Files))
+	)
+	for file := range p.Files {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		fr := p.Files[file]
+		parse, ok := outputParsers[strings.ToLower(path.Ext(file))]
+		if !ok {
+			if len(fr.schemas) > 0 {
+				errs = errs.add(&TemplateError{File: file, Message: "can not validate against a schema, only json, yaml and toml files can be validated"})
+			}
+			continue
+		}
+		documents, err := parse(fr.bytes)
+		if err != nil {
+			errs = errs.add(&TemplateError{File: file, Message: "invalid " + strings.TrimPrefix(path.Ext(file), ".") + ": " + err.Error(), Err: err})
+			continue
+		}
+		for _, schemaFile := range fr.schemas {
+			schema, ok := schemas[schemaFile]
+			if !ok {
+				schema, err = compiler.Compile(schemaFile)
+				if err != nil {
+					return fmt.Errorf("could not compile schema %q: %q", schemaFile, err)
+				}
+				schemas[schemaFile] = schema
+			}
+			for i, document := range documents {
+				err = validateDocument(schema, document)
+				if err != nil {
+					location := file
+					if len(documents) > 1 {
+						location = fmt.Sprintf("%s document %d", file, i+1)
+					}
+					errs = errs.add(&TemplateError{File: location, Message: "does not match " + schemaFile + ": " + err.Error(), Err: err})
+				}
+			}
+		}
+	}
+	return errs.err()
+}
+
+// validateDocument validates json compatible values, that json numbers have been used for
+func validateDocument(schema *jsonschema.Schema, document interface{}) error {
+	// yaml and toml values have other types than the ones encoding/json produces
+	documentBytes, err := json.Marshal(document)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(documentBytes))
+	decoder.UseNumber()
+	err = decoder.Decode(&document)
+	if err != nil {
+		return err
+	}
+	err = schema.Validate(document)
+	var validationError *jsonschema.ValidationError
+	if errors.As(err, &validationError) {
+		return errors.New(strings.Join(getValidationMessages(validationError), ", "))
+	}
+	return err
+}
+
+// getValidationMessages flattens the causes of validation errors to their leaves
+func getValidationMessages(err *jsonschema.ValidationError) (messages []string) {
+	if len(err.Causes) == 0 {
+		location := err.InstanceLocation
+		if location == "" {
+			location = "/"
+		}
+		return []string{location + " " + err.Message}
+	}
+	for _, cause := range err.Causes {
+		messages = append(messages, getValidationMessages(cause)...)
+	}
+	return messages
+}
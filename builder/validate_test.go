@@ -0,0 +1,90 @@
+package builder
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	bobconfig := "schemas:\n  'app.*': " + GetExample("schemas/port.json")
+	tests := []struct {
+		name         string
+		files        map[string]string
+		port         interface{}
+		wantFiles    []string
+		wantMessages []string
+	}{
+		{"valid", map[string]string{
+			".bobconfig": bobconfig,
+			"app.json":   `{"port": {{ .port }}}`,
+			"app.yml":    "port: {{ .port }}\n---\nport: {{ .port }}\n",
+			"app.toml":   "port = {{ .port }}",
+			"other.txt":  "not validated",
+		}, 80, nil, nil},
+		{"invalid files", map[string]string{
+			".bobconfig":        bobconfig,
+			"app.json":          `{"port": {{ .port }}}`,
+			"app.txt":           "can not be validated",
+			"other/app.yaml":    "port: [{{ .port }}]",
+			"other/broken.json": `{"port": }`,
+			"indent.yml":        "a:\n{{ indent \"b: 1\\n c: 2\" \"  \" }}",
+		}, 80, []string{"app.txt", "indent.yml", "other/app.yaml", "other/broken.json"}, []string{
+			"/port expected integer, but got array",
+		}},
+		{"schema mismatches", map[string]string{
+			".bobconfig": bobconfig,
+			"app.json":   `{"port": {{ .port }}}`,
+			"app.yml":    "port: {{ .port }}\n---\nport: {{ .port }}\n",
+			"app.toml":   "port = {{ .port }}",
+		}, `"http"`, []string{"app.json", "app.toml", "app.yml document 1", "app.yml document 2"}, []string{
+			"app.yml document 1: does not match",
+			"app.yml document 2: does not match",
+			"app.json: does not match",
+			"app.toml: does not match",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := &Args{
+				SourceFolders: []string{getTestFolder(t, tt.files)},
+				Overrides:     []DataOverride{{Path: "port", Value: tt.port}},
+			}
+			result, err := Build(args)
+			if err != nil {
+				t.Fatal("Build() without validation", err)
+			}
+			for file, fr := range result.Files {
+				wantSchemas := []string(nil)
+				if strings.HasPrefix(file, "app.") || strings.HasPrefix(file, "other/app.") {
+					wantSchemas = []string{GetExample("schemas/port.json")}
+				}
+				if !reflect.DeepEqual(fr.schemas, wantSchemas) {
+					t.Errorf("Build() schemas of %q = %v, want %v", file, fr.schemas, wantSchemas)
+				}
+			}
+
+			args.Validate = true
+			_, err = Build(args)
+			var files []string
+			var errs Errors
+			if errors.As(err, &errs) {
+				for _, err := range errs {
+					var templateError *TemplateError
+					if errors.As(err, &templateError) {
+						files = append(files, templateError.File)
+					}
+				}
+			}
+			if (err != nil) != (len(tt.wantFiles) > 0) || !reflect.DeepEqual(files, tt.wantFiles) {
+				t.Errorf("Build() with validation error = %v, want errors in %v", err, tt.wantFiles)
+			}
+			for _, message := range tt.wantMessages {
+				if err == nil || !strings.Contains(err.Error(), message) {
+					t.Errorf("Build() with validation error = %v, want %q", err, message)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,299 @@
+package builder
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// WatchOptions control how often a watch looks for changes
+type WatchOptions struct {
+	// Interval between two looks at the source folders and data files
+	Interval time.Duration
+	// SecretsInterval if > 0 secrets are read again in this interval and changed secrets trigger a build
+	SecretsInterval time.Duration
+}
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+	isDir   bool
+}
+
+type watcher struct {
+	args     *Args
+	renderer *renderer
+	result   *ProcessingResult
+	snapshot map[string]fileState
+	// failed is true, if the last build has failed
+	failed bool
+}
+
+// Watch builds and calls write with the result, then it keeps looking for changes in the source folders and data
+// files and calls write again after every rebuild, that changed the result, until stop is closed. When only
+// templates have changed, just those are rendered again. Build errors are printed and do not stop watching.
+func Watch(args *Args, options WatchOptions, write func(result *ProcessingResult) error, stop <-chan struct{}) error {
+	if options.Interval <= 0 {
+		return fmt.Errorf("watch interval has to be positive, got %s", options.Interval)
+	}
+	w := &watcher{args: args}
+	err := w.build()
+	if err != nil {
+		fmt.Println("a build error has occurred:", err.Error())
+	} else {
+		err = write(w.result.clone())
+		if err != nil {
+			return err
+		}
+	}
+	fmt.Println(line)
+	fmt.Println("watching", strings.Join(append(append([]string{}, args.SourceFolders...), args.DataFiles...), ", "))
+	fmt.Println(line)
+
+	ticker := time.NewTicker(options.Interval)
+	defer ticker.Stop()
+	lastSecretRefresh := time.Now()
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+		}
+		refreshSecrets := options.SecretsInterval > 0 && time.Since(lastSecretRefresh) >= options.SecretsInterval
+		if refreshSecrets {
+			lastSecretRefresh = time.Now()
+		}
+		start := time.Now()
+		previous := w.result
+		reason, err := w.update(refreshSecrets)
+		if reason == "" {
+			continue
+		}
+		summary := fmt.Sprintf("[%s] %s", start.Format("15:04:05"), reason)
+		if err != nil {
+			fmt.Println(summary + ", build failed: " + err.Error())
+			continue
+		}
+		changed := previous.changedFiles(w.result)
+		if len(changed) == 0 && previous != nil && len(w.result.Folders) == len(previous.Folders) {
+			fmt.Println(summary+", no changes in", time.Since(start).Round(time.Millisecond))
+			continue
+		}
+		err = write(w.result.clone())
+		if err != nil {
+			fmt.Println(summary + ", could not write: " + err.Error())
+			continue
+		}
+		fmt.Println(summary+", written", len(changed), "changed files", "("+strings.Join(changed, ", ")+")", "in", time.Since(start).Round(time.Millisecond))
+	}
+}
+
+func (w *watcher) build() (err error) {
+	snapshot, err := w.getSnapshot()
+	if err != nil {
+		return err
+	}
+	result, r, err := build(w.args)
+	// even a failed build has seen this snapshot
+	w.snapshot = snapshot
+	w.failed = err != nil
+	if err != nil {
+		return err
+	}
+	w.result, w.renderer = result, r
+	return nil
+}
+
+// update looks for changes and builds, if necessary. An empty reason means nothing has been built.
+func (w *watcher) update(refreshSecrets bool) (reason string, err error) {
+	snapshot, err := w.getSnapshot()
+	if err != nil {
+		return "could not look for changes", err
+	}
+	changed, structural := w.diffSnapshot(snapshot)
+	switch {
+	case len(changed) == 0 && !refreshSecrets:
+		return "", nil
+	case refreshSecrets || structural || w.failed:
+		if refreshSecrets {
+			reason = "refreshing secrets"
+			clearSecretCache()
+		} else {
+			reason = strings.Join(changed, ", ") + " changed"
+		}
+		return reason + ", full build", w.build()
+	}
+	w.snapshot = snapshot
+	reason = strings.Join(changed, ", ") + " changed"
+	fullBuild, err := w.processChangedFiles(changed)
+	if fullBuild {
+		return reason + ", full build", w.build()
+	}
+	// the result is stale now, the next change has to build everything again
+	w.failed = err != nil
+	return reason, err
+}
+
+// processChangedFiles renders the changed templates again, that have made it into the result, with the settings of
+// their folder. Templates, that skip, stop skipping or are renamed by their front matter, need a full build.
+func (w *watcher) processChangedFiles(changed []string) (fullBuild bool, err error) {
+	sources := map[string]string{}
+	for file, fr := range w.result.Files {
+		if fr.sourceFolder != "" {
+			sources[path.Join(fr.sourceFolder, fr.source)] = file
+		}
+	}
+	skippedSources := map[string]bool{}
+	for _, skipped := range w.result.Skipped {
+		skippedSources[skipped.Source] = true
+	}
+	result := w.result.clone()
+	w.renderer.missingKeys = &missingKeyReport{}
+	configs := map[string]*folderConfigs{}
+	for _, filename := range changed {
+		if skippedSources[filename] {
+			return true, nil
+		}
+		file, ok := sources[filename]
+		if !ok {
+			// ignored or overridden by a later source folder
+			continue
+		}
+		previous := result.Files[file]
+		if configs[previous.sourceFolder] == nil {
+			configs[previous.sourceFolder] = newFolderConfigs(previous.sourceFolder)
+		}
+		config, err := configs[previous.sourceFolder].get(path.Dir(previous.source))
+		if err != nil {
+			return false, err
+		}
+		fr, err := processFile(filename, config.renderer(w.renderer), !previous.copied)
+		if _, ok := getSkipReason(err); ok {
+			return true, nil
+		}
+		if err != nil {
+			return false, newTemplateError(filename, err)
+		}
+		if fr.rename != previous.rename {
+			return true, nil
+		}
+		// the metadata, that the full build adds to what processFile returns
+		fr.sourceFolder = previous.sourceFolder
+		fr.source = previous.source
+		fr.schemas = previous.schemas
+		result.Files[file] = fr
+	}
+	err = w.renderer.missingKeys.err()
+	if err != nil {
+		return false, err
+	}
+	if w.args.Validate {
+		err = result.Validate()
+		if err != nil {
+			return false, err
+		}
+	}
+	w.result = result
+	return false, nil
+}
+
+// diffSnapshot returns the changed paths and if the change needs a full build, because of added or removed files,
+// changed data files, bob files, partials or files with templated paths
+func (w *watcher) diffSnapshot(snapshot map[string]fileState) (changed []string, structural bool) {
+	isDataFile := map[string]bool{}
+	for _, dataFile := range w.args.DataFiles {
+		isDataFile[dataFile] = true
+	}
+	for p, state := range snapshot {
+		oldState, ok := w.snapshot[p]
+		switch {
+		case !ok:
+			structural = true
+		case oldState == state:
+			continue
+		case isDataFile[p] || state.isDir != oldState.isDir || strings.HasPrefix(path.Base(p), ".bob") ||
+			strings.Contains("/"+p, "/"+partialsFolder+"/") || isTemplatedPath(p):
+			structural = true
+		}
+		changed = append(changed, p)
+	}
+	for p := range w.snapshot {
+		if _, ok := snapshot[p]; !ok {
+			structural = true
+			changed = append(changed, p)
+		}
+	}
+	sort.Strings(changed)
+	return changed, structural
+}
+
+func (w *watcher) getSnapshot() (snapshot map[string]fileState, err error) {
+	snapshot = map[string]fileState{}
+	for _, dataFile := range w.args.DataFiles {
+		info, err := os.Stat(dataFile)
+		if err != nil {
+			// a missing data file is a change, that the next build will complain about
+			continue
+		}
+		snapshot[dataFile] = fileState{modTime: info.ModTime(), size: info.Size()}
+	}
+	for _, sourceFolder := range w.args.SourceFolders {
+		sourceFolder = path.Clean(sourceFolder)
+		err := walk(sourceFolder, nil, func(p string, fileInfo os.FileInfo) bool {
+			targetInfo, err := resolve(fileInfo, p)
+			if err != nil {
+				return false
+			}
+			if targetInfo.IsDir() {
+				// the modification time of a folder changes with its entries, which are in the snapshot anyways
+				snapshot[p] = fileState{isDir: true}
+			} else {
+				snapshot[p] = fileState{modTime: targetInfo.ModTime(), size: targetInfo.Size()}
+			}
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return snapshot, nil
+}
+
+func (p *ProcessingResult) clone() *ProcessingResult {
+	clone := &ProcessingResult{
+		Folders: append([]string{}, p.Folders...),
+		Files:   map[string]*fileResult{},
+		Skipped: map[string]*SkippedFile{},
+	}
+	for file, fr := range p.Files {
+		clone.Files[file] = fr
+	}
+	for file, skipped := range p.Skipped {
+		clone.Skipped[file] = skipped
+	}
+	return clone
+}
+
+// changedFiles lists added, changed and removed files compared to another result
+func (p *ProcessingResult) changedFiles(other *ProcessingResult) (changed []string) {
+	if p == nil {
+		p = &ProcessingResult{}
+	}
+	for file, fr := range other.Files {
+		previous, ok := p.Files[file]
+		if !ok || previous.mode != fr.mode || !bytes.Equal(previous.bytes, fr.bytes) {
+			changed = append(changed, file)
+		}
+	}
+	for file := range p.Files {
+		if _, ok := other.Files[file]; !ok {
+			changed = append(changed, file)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
@@ -0,0 +1,200 @@
+package builder
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// touchTestFile writes a file with a modification time, that a watcher can tell apart from the previous one
+func touchTestFile(filename, contents string) {
+	time.Sleep(10 * time.Millisecond)
+	writeTestFile(filename, contents)
+}
+
+// getContents maps the files of a result to their rendered contents
+func getContents(result *ProcessingResult) map[string]string {
+	contents := map[string]string{}
+	if result == nil {
+		return contents
+	}
+	for file, fr := range result.Files {
+		contents[file] = string(fr.bytes)
+	}
+	return contents
+}
+
+func TestWatcherUpdate(t *testing.T) {
+	sourceFolder := getTestFolder(t, map[string]string{
+		"a.conf":     "a {{ .a }}",
+		"b/b.conf":   "b {{ .b }}",
+		".bobcopy":   "copy.txt",
+		"copy.txt":   "{{ .a }}",
+		".bobignore": "ignored.txt",
+	})
+	dataFile := filepath.Join(getTestFolder(t, map[string]string{"data.yaml": "a: 1\nb: 2\n"}), "data.yaml")
+	w := &watcher{args: &Args{
+		SourceFolders: []string{sourceFolder},
+		DataFiles:     []string{dataFile},
+	}}
+	panicOnErr(w.build())
+	// steps run in order, each one changes files and looks at the result of the next update
+	tests := []struct {
+		name        string
+		changes     map[string]string
+		wantReason  string
+		wantErr     bool
+		want        map[string]string
+		wantChanged []string
+	}{
+		{"nothing changed", nil, "", false, map[string]string{
+			"a.conf":   "a 1",
+			"b/b.conf": "b 2",
+			"copy.txt": "{{ .a }}",
+		}, nil},
+		{"only a template changed", map[string]string{
+			filepath.Join(sourceFolder, "a.conf"): "a is {{ .a }}",
+		}, filepath.Join(sourceFolder, "a.conf") + " changed", false, map[string]string{
+			"a.conf":   "a is 1",
+			"b/b.conf": "b 2",
+			"copy.txt": "{{ .a }}",
+		}, []string{"a.conf"}},
+		{"copied files stay copied, ignored files stay ignored", map[string]string{
+			filepath.Join(sourceFolder, "copy.txt"):    "{{ .b }}",
+			filepath.Join(sourceFolder, ".bobignore"):  "ignored.txt\n",
+			filepath.Join(sourceFolder, "ignored.txt"): "{{ .c }}",
+		}, filepath.Join(sourceFolder, ".bobignore") + ", " + filepath.Join(sourceFolder, "copy.txt") + ", " + filepath.Join(sourceFolder, "ignored.txt") + " changed, full build", false, map[string]string{
+			"a.conf":   "a is 1",
+			"b/b.conf": "b 2",
+			"copy.txt": "{{ .b }}",
+		}, []string{"copy.txt"}},
+		{"data changes lead to a full build", map[string]string{
+			dataFile: "a: 3\nb: 4\n",
+		}, dataFile + " changed, full build", false, map[string]string{
+			"a.conf":   "a is 3",
+			"b/b.conf": "b 4",
+			"copy.txt": "{{ .b }}",
+		}, []string{"a.conf", "b/b.conf"}},
+		{"broken templates keep the last result", map[string]string{
+			filepath.Join(sourceFolder, "new.conf"): "{{ .missing }}",
+		}, filepath.Join(sourceFolder, "new.conf") + " changed, full build", true, map[string]string{
+			"a.conf":   "a is 3",
+			"b/b.conf": "b 4",
+			"copy.txt": "{{ .b }}",
+		}, nil},
+		{"fixed templates are built", map[string]string{
+			filepath.Join(sourceFolder, "new.conf"): "{{ .a }}",
+		}, filepath.Join(sourceFolder, "new.conf") + " changed, full build", false, map[string]string{
+			"a.conf":   "a is 3",
+			"b/b.conf": "b 4",
+			"copy.txt": "{{ .b }}",
+			"new.conf": "3",
+		}, []string{"new.conf"}},
+		{"broken templates fail incremental builds", map[string]string{
+			filepath.Join(sourceFolder, "a.conf"): "{{ .missing }}",
+		}, filepath.Join(sourceFolder, "a.conf") + " changed", true, map[string]string{
+			"a.conf":   "a is 3",
+			"b/b.conf": "b 4",
+			"copy.txt": "{{ .b }}",
+			"new.conf": "3",
+		}, nil},
+		{"failed incremental builds lead to a full build", map[string]string{
+			filepath.Join(sourceFolder, "a.conf"): "a was {{ .a }}",
+		}, filepath.Join(sourceFolder, "a.conf") + " changed, full build", false, map[string]string{
+			"a.conf":   "a was 3",
+			"b/b.conf": "b 4",
+			"copy.txt": "{{ .b }}",
+			"new.conf": "3",
+		}, []string{"a.conf"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for filename, contents := range tt.changes {
+				touchTestFile(filename, contents)
+			}
+			previous := w.result
+			reason, err := w.update(false)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("update() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if reason != tt.wantReason {
+				t.Errorf("update() reason = %q, want %q", reason, tt.wantReason)
+			}
+			if got := getContents(w.result); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("update() result = %v, want %v", got, tt.want)
+			}
+			if changed := previous.changedFiles(w.result); !reflect.DeepEqual(changed, tt.wantChanged) {
+				t.Errorf("changedFiles() = %v, want %v", changed, tt.wantChanged)
+			}
+		})
+	}
+}
+
+func TestWatchInterval(t *testing.T) {
+	tests := []struct {
+		name     string
+		interval time.Duration
+	}{
+		{"zero", 0},
+		{"negative", -time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stop := make(chan struct{})
+			close(stop)
+			err := Watch(&Args{SourceFolders: []string{GetExample("source-simple")}}, WatchOptions{Interval: tt.interval}, func(*ProcessingResult) error {
+				return nil
+			}, stop)
+			if err == nil {
+				t.Error("Watch() expected an error")
+			}
+		})
+	}
+}
+
+func TestWatcherUpdateFolderConfig(t *testing.T) {
+	sourceFolder := getTestFolder(t, map[string]string{
+		"d/.bobconfig": "delims: ['[[', ']]']\nschemas:\n  '*.json': " + GetExample("schemas/port.json") + "\n",
+		"d/d.conf":     "d [[ .a ]] {{ keep }}",
+		"d/app.json":   `{"port": [[ .a ]]}`,
+	})
+	w := &watcher{args: &Args{
+		SourceFolders: []string{sourceFolder},
+		Overrides:     []DataOverride{{Path: "a", Value: 1}},
+		Validate:      true,
+	}}
+	panicOnErr(w.build())
+	// steps run in order like in TestWatcherUpdate
+	tests := []struct {
+		name    string
+		file    string
+		changed string
+		wantErr bool
+		want    map[string]string
+	}{
+		{"delims of the folder config", "d/d.conf", "d is [[ .a ]] {{ keep }}", false, map[string]string{
+			"d/d.conf":   "d is 1 {{ keep }}",
+			"d/app.json": `{"port": 1}`,
+		}},
+		{"schemas of the folder config", "d/app.json", `{"port": "[[ .a ]]"}`, true, map[string]string{
+			"d/d.conf":   "d is 1 {{ keep }}",
+			"d/app.json": `{"port": 1}`,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			touchTestFile(filepath.Join(sourceFolder, tt.file), tt.changed)
+			reason, err := w.update(false)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("update() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if want := filepath.Join(sourceFolder, tt.file) + " changed"; reason != want {
+				t.Errorf("update() reason = %q, want %q", reason, want)
+			}
+			if got := getContents(w.result); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("update() result = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
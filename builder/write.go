@@ -0,0 +1,284 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// WriteOptions control how a processing result ends up in the target folder
+type WriteOptions struct {
+	// Prune removes files and empty folders, that are not part of the result
+	Prune bool
+	// Protected paths survive pruning
+	Protected []string
+	// Atomic writes the result into a new generation folder and then points the target folder, which is a symlink,
+	// at it
+	Atomic bool
+	// Keep the given number of previous generations of the target folder for rollbacks, when writing atomically
+	Keep int
+}
+
+const (
+	generationsSuffix   = ".bob-generations"
+	stagePrefix         = ".bob-stage-"
+	linkPrefix          = ".bob-link-"
+	generationTimestamp = "20060102T150405.000000000"
+)
+
+// Write a processing result into the target folder according to the given options
+func Write(targetFolder string, result *ProcessingResult, options WriteOptions) error {
+	if options.Atomic {
+		return writeAtomic(targetFolder, result, options)
+	}
+	if options.Prune {
+		_, err := Prune(targetFolder, result, options.Protected)
+		if err != nil {
+			return err
+		}
+	}
+	return WriteProcessingResult(targetFolder, result)
+}
+
+// writeAtomic copies the current generation into a stage folder next to the generations, writes and prunes the stage
+// and makes it the new generation. Then a symlink to the new generation is renamed over the target folder, so readers
+// either see the old or the new generation, but never a partially written one or nothing at all.
+func writeAtomic(targetFolder string, result *ProcessingResult, options WriteOptions) error {
+	targetFolder = filepath.Clean(targetFolder)
+	// a target folder, that is no symlink yet, becomes the oldest generation
+	replaced := time.Now().UTC().Format(generationTimestamp)
+	generationsFolder := getGenerationsFolder(targetFolder)
+	err := os.MkdirAll(generationsFolder, 0755)
+	if err != nil {
+		return err
+	}
+	stageFolder, err := ioutil.TempDir(generationsFolder, stagePrefix)
+	if err != nil {
+		return errors.New("could not create stage folder: " + err.Error())
+	}
+	cleanUp := func(err error) error {
+		_ = os.RemoveAll(stageFolder)
+		_ = os.Remove(generationsFolder)
+		return err
+	}
+	targetInfo, err := os.Stat(targetFolder)
+	targetExists := err == nil
+	if targetExists {
+		if !targetInfo.IsDir() {
+			return cleanUp(fmt.Errorf("target %q is not a folder", targetFolder))
+		}
+		fmt.Println(line)
+		fmt.Println("staging current target folder in", stageFolder)
+		err = copyTree(getCurrentFolder(targetFolder), stageFolder)
+		if err != nil {
+			return cleanUp(errors.New("could not stage target folder: " + err.Error()))
+		}
+		err = os.Chmod(stageFolder, targetInfo.Mode().Perm())
+	} else {
+		err = os.Chmod(stageFolder, 0744)
+	}
+	if err != nil {
+		return cleanUp(err)
+	}
+	if options.Prune {
+		_, err = Prune(stageFolder, result, options.Protected)
+		if err != nil {
+			return cleanUp(err)
+		}
+	}
+	err = WriteProcessingResult(stageFolder, result)
+	if err != nil {
+		return cleanUp(err)
+	}
+
+	fmt.Println(line)
+	fmt.Println("swapping in new generation of", targetFolder)
+	fmt.Println(line)
+	generation := filepath.Join(generationsFolder, time.Now().UTC().Format(generationTimestamp))
+	err = os.Rename(stageFolder, generation)
+	if err != nil {
+		return cleanUp(errors.New("could not turn stage folder into a generation: " + err.Error()))
+	}
+	previous := getCurrentGeneration(targetFolder)
+	err = linkGeneration(targetFolder, generation, filepath.Join(generationsFolder, replaced))
+	if err != nil {
+		_ = os.RemoveAll(generation)
+		_ = os.Remove(generationsFolder)
+		return errors.New("could not swap in new generation: " + err.Error())
+	}
+	if previous != "" {
+		fmt.Println("previous generation kept in", previous)
+	} else if targetExists {
+		fmt.Println("previous target folder kept in", filepath.Join(generationsFolder, replaced))
+	}
+	return trimGenerations(targetFolder, options.Keep)
+}
+
+// Rollback points the target folder at the latest previous generation, that was kept by an atomic write, and removes
+// the current one
+func Rollback(targetFolder string) error {
+	targetFolder = filepath.Clean(targetFolder)
+	generations, err := getGenerations(targetFolder)
+	if err != nil {
+		return err
+	}
+	if len(generations) == 0 {
+		return fmt.Errorf("there is no generation of %q to roll back to", targetFolder)
+	}
+	generation := generations[len(generations)-1]
+	fmt.Println(line)
+	fmt.Println("rolling back", targetFolder, "to", generation)
+	fmt.Println(line)
+	discarded := getCurrentGeneration(targetFolder)
+	if discarded == "" {
+		discarded = filepath.Join(getGenerationsFolder(targetFolder), "discarded-"+time.Now().UTC().Format(generationTimestamp))
+	}
+	err = linkGeneration(targetFolder, generation, discarded)
+	if err != nil {
+		return errors.New("could not restore generation: " + err.Error())
+	}
+	return os.RemoveAll(discarded)
+}
+
+// linkGeneration renames a new symlink to the generation over the target folder. A target folder, that is no symlink
+// yet, has to be moved to replaced first, only then there is a moment without a target folder.
+func linkGeneration(targetFolder, generation, replaced string) error {
+	parentFolder, name := filepath.Split(targetFolder)
+	tempLink := filepath.Join(parentFolder, "."+name+linkPrefix+time.Now().UTC().Format(generationTimestamp))
+	// relative, so that the target folder can be moved together with its generations
+	err := os.Symlink(filepath.Join("."+name+generationsSuffix, filepath.Base(generation)), tempLink)
+	if err != nil {
+		return err
+	}
+	info, err := os.Lstat(targetFolder)
+	moved := err == nil && info.Mode()&os.ModeSymlink == 0
+	if moved {
+		err = os.Rename(targetFolder, replaced)
+		if err != nil {
+			_ = os.Remove(tempLink)
+			return err
+		}
+	}
+	err = os.Rename(tempLink, targetFolder)
+	if err != nil {
+		_ = os.Remove(tempLink)
+		if moved {
+			_ = os.Rename(replaced, targetFolder)
+		}
+		return err
+	}
+	return nil
+}
+
+// getCurrentGeneration returns the generation the target folder links to or "", when it is no symlink
+func getCurrentGeneration(targetFolder string) string {
+	link, err := os.Readlink(targetFolder)
+	if err != nil {
+		return ""
+	}
+	if !filepath.IsAbs(link) {
+		link = filepath.Join(filepath.Dir(targetFolder), link)
+	}
+	return filepath.Clean(link)
+}
+
+// getCurrentFolder returns the folder, that holds the files of the target folder
+func getCurrentFolder(targetFolder string) string {
+	if generation := getCurrentGeneration(targetFolder); generation != "" {
+		return generation
+	}
+	return targetFolder
+}
+
+func getGenerationsFolder(targetFolder string) string {
+	parentFolder, name := filepath.Split(filepath.Clean(targetFolder))
+	return filepath.Join(parentFolder, "."+name+generationsSuffix)
+}
+
+// getGenerations returns the previous generations of a target folder oldest first, the current one is not part of them
+func getGenerations(targetFolder string) (generations []string, err error) {
+	generationsFolder := getGenerationsFolder(targetFolder)
+	fileInfos, err := ioutil.ReadDir(generationsFolder)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	current := getCurrentGeneration(targetFolder)
+	for _, fileInfo := range fileInfos {
+		generation := filepath.Join(generationsFolder, fileInfo.Name())
+		if _, err := time.Parse(generationTimestamp, fileInfo.Name()); err == nil && fileInfo.IsDir() && generation != current {
+			generations = append(generations, generation)
+		}
+	}
+	sort.Strings(generations)
+	return generations, nil
+}
+
+func trimGenerations(targetFolder string, keep int) error {
+	generations, err := getGenerations(targetFolder)
+	if err != nil {
+		return err
+	}
+	for len(generations) > 0 && len(generations) > keep {
+		fmt.Println("removing old generation", generations[0])
+		err := os.RemoveAll(generations[0])
+		if err != nil {
+			return err
+		}
+		generations = generations[1:]
+	}
+	if len(generations) == 0 {
+		_ = os.Remove(getGenerationsFolder(targetFolder))
+	}
+	return nil
+}
+
+// copyTree copies files, folders and symlinks with their permissions
+func copyTree(source, target string) error {
+	return filepath.Walk(source, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relativePath, err := filepath.Rel(source, p)
+		if err != nil {
+			return err
+		}
+		targetPath := filepath.Join(target, relativePath)
+		switch {
+		case info.Mode()&os.ModeSymlink == os.ModeSymlink:
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, targetPath)
+		case info.IsDir():
+			return os.MkdirAll(targetPath, info.Mode().Perm())
+		default:
+			return copyFile(p, targetPath, info.Mode().Perm())
+		}
+	})
+}
+
+func copyFile(source, target string, perm os.FileMode) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,141 @@
+package builder
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func readTestFile(filename string) string {
+	contents, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err.Error()
+	}
+	return string(contents)
+}
+
+func TestWriteAtomic(t *testing.T) {
+	targetFolder := filepath.Join(getTestFolder(t, nil), "target")
+	options := WriteOptions{Atomic: true, Keep: 2, Prune: true, Protected: []string{"local.conf"}}
+	tests := []struct {
+		name            string
+		write           string
+		rollback        bool
+		wantErr         bool
+		wantContents    string
+		wantGenerations int
+	}{
+		{"first build", "1", false, false, "1", 0},
+		{"second build", "2", false, false, "2", 1},
+		{"third build", "3", false, false, "3", 2},
+		{"older generations are trimmed", "4", false, false, "4", 2},
+		{"rollback", "", true, false, "3", 1},
+		{"second rollback", "", true, false, "2", 0},
+		{"nothing to roll back to", "", true, true, "2", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err error
+			if tt.rollback {
+				err = Rollback(targetFolder)
+			} else {
+				err = Write(targetFolder, getTestResult(t, map[string]string{"a.txt": tt.write}), options)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if contents := readTestFile(filepath.Join(targetFolder, "a.txt")); contents != tt.wantContents {
+				t.Errorf("a.txt = %q, want %q", contents, tt.wantContents)
+			}
+			generations, err := getGenerations(targetFolder)
+			panicOnErr(err)
+			if len(generations) != tt.wantGenerations {
+				t.Errorf("generations = %v, want %d", generations, tt.wantGenerations)
+			}
+			if getCurrentGeneration(targetFolder) == "" {
+				t.Error("target folder is no symlink to a generation")
+			}
+			// no stage folders or links left behind
+			left, err := filepath.Glob(filepath.Join(getGenerationsFolder(targetFolder), stagePrefix+"*"))
+			panicOnErr(err)
+			links, err := filepath.Glob(filepath.Join(filepath.Dir(targetFolder), ".target"+linkPrefix+"*"))
+			panicOnErr(err)
+			if left = append(left, links...); len(left) > 0 {
+				t.Errorf("left behind: %v", left)
+			}
+		})
+		if tt.name == "first build" {
+			panicOnErr(ioutil.WriteFile(filepath.Join(targetFolder, "local.conf"), []byte("mine"), 0600))
+		}
+	}
+	// protected files, that are not part of the result, are carried over into every generation
+	if contents := readTestFile(filepath.Join(targetFolder, "local.conf")); contents != "mine" {
+		t.Errorf("local.conf = %q, want %q", contents, "mine")
+	}
+}
+
+func TestWriteAtomicPlainTargetFolder(t *testing.T) {
+	targetFolder := filepath.Join(getTestFolder(t, map[string]string{"target/a.txt": "plain"}), "target")
+	if err := Write(targetFolder, getTestResult(t, map[string]string{"a.txt": "new"}), WriteOptions{Atomic: true, Keep: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if getCurrentGeneration(targetFolder) == "" {
+		t.Error("target folder is no symlink to a generation")
+	}
+	if contents := readTestFile(filepath.Join(targetFolder, "a.txt")); contents != "new" {
+		t.Errorf("a.txt = %q, want %q", contents, "new")
+	}
+	// the plain target folder is the generation to roll back to
+	if err := Rollback(targetFolder); err != nil {
+		t.Fatal(err)
+	}
+	if contents := readTestFile(filepath.Join(targetFolder, "a.txt")); contents != "plain" {
+		t.Errorf("a.txt = %q, want %q", contents, "plain")
+	}
+}
+
+func TestWriteAtomicFailureKeepsTarget(t *testing.T) {
+	targetFolder := getTestFolder(t, map[string]string{"a.txt": "old", "b.txt": "old"})
+	result := getTestResult(t, map[string]string{"a.txt": "new", "b.txt/c.txt": "new"})
+	// b.txt is a file in the target, so b.txt/c.txt can not be written
+	if err := Write(targetFolder, result, WriteOptions{Atomic: true, Keep: 1}); err == nil {
+		t.Error("Write() expected an error")
+	}
+	if contents := readTestFile(filepath.Join(targetFolder, "a.txt")); contents != "old" {
+		t.Errorf("a.txt = %q, want %q", contents, "old")
+	}
+	if _, err := os.Stat(getGenerationsFolder(targetFolder)); !os.IsNotExist(err) {
+		t.Error("a generation has been kept for a failed write", err)
+	}
+}
+
+func TestTrimGenerations(t *testing.T) {
+	tests := []struct {
+		name            string
+		keep            int
+		wantGenerations int
+	}{
+		{"keep more", 3, 2},
+		{"keep all", 2, 2},
+		{"keep one", 1, 1},
+		{"keep none", 0, 0},
+		{"negative", -1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			targetFolder := filepath.Join(getTestFolder(t, map[string]string{
+				".target" + generationsSuffix + "/20200101T000000.000000000/a.txt": "1",
+				".target" + generationsSuffix + "/20200102T000000.000000000/a.txt": "2",
+			}), "target")
+			if err := trimGenerations(targetFolder, tt.keep); err != nil {
+				t.Fatal(err)
+			}
+			generations, err := getGenerations(targetFolder)
+			panicOnErr(err)
+			if len(generations) != tt.wantGenerations {
+				t.Errorf("trimGenerations() kept %v, want %d", generations, tt.wantGenerations)
+			}
+		})
+	}
+}
@@ -1,17 +1,23 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"os"
 	"os/exec"
+	"os/signal"
 	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/bgentry/speakeasy"
 	"github.com/foomo/config-bob/builder"
 	"github.com/foomo/config-bob/config"
 	"github.com/foomo/config-bob/vault"
 	"github.com/foomo/htpasswd"
-	"log"
+	"gopkg.in/yaml.v2"
 	"path/filepath"
 )
 
@@ -26,6 +32,7 @@ var (
 const helpCommands = `
 Commands:
     build           my main task
+    lint            check templates, data files and patterns without building
     vault-local     set up a local vault
     vault-htpasswd  update htpasswd files
     vault-tree      show a recursive listing in vault
@@ -35,6 +42,7 @@ Commands:
 const (
 	commandVersion    = "version"
 	commandBuild      = "build"
+	commandLint       = "lint"
 	commandVaultLocal = "vault-local"
 	commandVaultTree  = "vault-tree"
 	commandHtpasswd   = "vault-htpasswd"
@@ -53,6 +61,37 @@ func init() {
 	}
 }
 
+// exit revokes the vault token, that bob may have logged in with
+func exit(code int) {
+	err := vault.CloseDefaultClient()
+	if err != nil {
+		fmt.Println(err.Error())
+	}
+	os.Exit(code)
+}
+
+var (
+	// interrupted is closed on SIGINT and SIGTERM
+	interrupted = make(chan struct{})
+	// stopsOnSignal is set by commands, that stop on their own, when interrupted is closed
+	stopsOnSignal atomic.Bool
+)
+
+// handleSignals makes bob exit on SIGINT and SIGTERM, so that the vault token, that he may have logged in with, is
+// revoked
+func handleSignals() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		received := <-signals
+		close(interrupted)
+		if !stopsOnSignal.Load() {
+			fmt.Println("exiting on", received)
+			exit(1)
+		}
+	}()
+}
+
 func isHelpFlag(arg string) bool {
 	switch arg {
 	case "--help", "-help", "-h":
@@ -63,7 +102,7 @@ func isHelpFlag(arg string) bool {
 
 func help() {
 	fmt.Println("usage:", os.Args[0], "<command>")
-	fmt.Println(helpCommands)
+	fmt.Print(helpCommands)
 }
 
 func versionCommand() {
@@ -73,7 +112,7 @@ func versionCommand() {
 func vaultTreeCommand() {
 	if len(os.Args) != 3 {
 		fmt.Println("usage: ", os.Args[0], commandVaultTree, "path/in/vault")
-		os.Exit(1)
+		exit(1)
 	}
 	fmt.Println("vault tree:")
 	path := strings.TrimRight(os.Args[2], "/") + "/"
@@ -81,14 +120,14 @@ func vaultTreeCommand() {
 	err := vault.Tree(path)
 	if err != nil {
 		fmt.Println("failed to show tree", err)
-		os.Exit(1)
+		exit(1)
 	}
 }
 
 func htpasswdCommand() {
 	htpasswdLocalUsage := func() {
 		fmt.Println("usage: ", os.Args[0], commandHtpasswd, "path/to/htpasswd.yaml")
-		os.Exit(1)
+		exit(1)
 	}
 	if len(os.Args) != 3 {
 		htpasswdLocalUsage()
@@ -96,7 +135,7 @@ func htpasswdCommand() {
 	err := vault.WriteHtpasswdFiles(os.Args[2], htpasswd.HashBCrypt)
 	if err != nil {
 		fmt.Println("failed", err)
-		os.Exit(1)
+		exit(1)
 
 	}
 	fmt.Println("DONE")
@@ -105,7 +144,7 @@ func htpasswdCommand() {
 func vaultLocalCommand() {
 	vaultLocalUsage := func() {
 		fmt.Println("usage: ", os.Args[0], commandVaultLocal, "path/to/vault/folder")
-		os.Exit(1)
+		exit(1)
 	}
 	if len(os.Args) >= 3 {
 		if isHelpFlag(os.Args[2]) {
@@ -114,7 +153,7 @@ func vaultLocalCommand() {
 		vaultFolder, err := filepath.Abs(os.Args[2])
 		if err != nil {
 			fmt.Println(err.Error())
-			os.Exit(1)
+			exit(1)
 		}
 		vault.LocalSetEnv()
 		if !vault.LocalIsSetUp(vaultFolder) {
@@ -122,12 +161,12 @@ func vaultLocalCommand() {
 			err := vault.LocalSetup(vaultFolder)
 			if err != nil {
 				fmt.Println(err.Error())
-				os.Exit(1)
+				exit(1)
 			}
 		}
 		if vault.LocalIsRunning() {
 			fmt.Println("there is already a vault running aborting")
-			os.Exit(1)
+			exit(1)
 		}
 		fmt.Println("vault not running - trying to start it")
 
@@ -142,17 +181,11 @@ func vaultLocalCommand() {
 		}
 
 		for _, vaultKey := range vaultKeys {
-			unsealCommand, err := vault.GetUnsealCommand(vaultKey)
-			fmt.Println(unsealCommand)
-			if err != nil {
-				log.Fatal(err)
-			}
-
-			out, err := unsealCommand.CombinedOutput()
+			sealStatus, err := vault.Unseal(vaultKey)
 			if err != nil {
-				fmt.Println("could not unseal vault", err, string(out))
+				fmt.Println("could not unseal vault", err)
 			} else {
-				fmt.Println(string(out))
+				fmt.Println("sealed:", sealStatus.Sealed, "progress:", sealStatus.Progress, "/", sealStatus.T)
 				//STORE VALID CREDENTIALS FOR VAULT
 				fmt.Println("VAULT-STORE: Persisting valid token/key values for vault")
 				if useVaultKeyStore {
@@ -202,7 +235,7 @@ func vaultLocalCommand() {
 		fmt.Println("Killed vault command process with PID: ", vaultCommand.Process.Pid)
 
 		if runErr != nil {
-			os.Exit(2)
+			exit(2)
 		} else {
 			fmt.Println("config bob says bye, bye")
 		}
@@ -228,7 +261,7 @@ func getVaultToken(vaultFolder string) string {
 	vaultToken, err := speakeasy.Ask("enter vault token:")
 	if err != nil {
 		fmt.Println("could not read token", err)
-		os.Exit(1)
+		exit(1)
 	}
 	if len(vaultToken) > 0 {
 		fmt.Println("Using token from standard input", vaultToken)
@@ -257,7 +290,7 @@ func getVaultKeys(vaultFolder string) (vaultKeys []string) {
 		vaultKey, err := speakeasy.Ask(fmt.Sprintf("vault key %d:", keyNumber))
 		if err != nil {
 			fmt.Println("vault key")
-			os.Exit(1)
+			exit(1)
 		}
 		if len(vaultKey) == 0 {
 			break
@@ -268,42 +301,378 @@ func getVaultKeys(vaultFolder string) (vaultKeys []string) {
 	return
 }
 
+// stringsFlag collects the values of a repeated flag
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ", ")
+}
+
+func (s *stringsFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// overridesFlag parses data overrides and collects them in the order they were given
+type overridesFlag struct {
+	overrides *[]builder.DataOverride
+	parse     func(override string) (builder.DataOverride, error)
+}
+
+func (o overridesFlag) String() string {
+	return ""
+}
+
+func (o overridesFlag) Set(value string) error {
+	override, err := o.parse(value)
+	if err != nil {
+		return err
+	}
+	*o.overrides = append(*o.overrides, override)
+	return nil
+}
+
+// buildJob is a build of one target folder with everything, that is needed to write or plan it
+type buildJob struct {
+	name         string
+	args         *builder.Args
+	manifest     string
+	plan         bool
+	writeOptions builder.WriteOptions
+	secrets      *builder.SecretSettings
+}
+
+// getProjectBuildJobs loads the project file and returns jobs for the named targets or all of them with the profile
+// applied, if there is one
+func getProjectBuildJobs(projectFile, profile string, names []string, all bool) (jobs []*buildJob, err error) {
+	if all && len(names) > 0 {
+		return nil, errors.New("either name a target or build --all of them")
+	}
+	project, err := builder.LoadProject(projectFile)
+	if err != nil {
+		return nil, errors.New("could not load project: " + err.Error())
+	}
+	if profile != "" {
+		err = project.ApplyProfile(profile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if all {
+		names = project.TargetNames()
+	}
+	for _, name := range names {
+		target, err := project.GetTarget(name)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, &buildJob{
+			name:         name,
+			args:         target.Args(),
+			manifest:     target.Manifest,
+			plan:         target.Plan,
+			writeOptions: target.WriteOptions(),
+			secrets:      target.Secrets,
+		})
+	}
+	return jobs, nil
+}
+
 func buildCommand() {
+	flags := flag.NewFlagSet(commandBuild, flag.ExitOnError)
+	projectFile := flags.String("project", builder.DefaultProjectFile, "project file with named targets, that are built with build <target> or build --all")
+	all := flags.Bool("all", false, "build all targets of the project file")
+	profile := flags.String("profile", "", "profile of the project file to layer on top of the targets like dev or prod")
+	plan := flags.Bool("plan", false, "show what would change in the target folder without writing anything")
+	prune := flags.Bool("prune", false, "remove files and empty folders from the target folder, that are not part of the build")
+	var protected stringsFlag
+	flags.Var(&protected, "protect", "path or glob relative to the target folder, that will not be pruned (repeatable)")
+	atomic := flags.Bool("atomic", false, "write the build into a new generation and point the target folder symlink at it, when everything has been written")
+	keep := flags.Int("keep", 1, "number of previous generations of the target folder to keep for rollbacks, when building atomically")
+	manifest := flags.String("manifest", "", "add a json manifest of the build with the given name relative to the target folder")
+	watch := flags.Bool("watch", false, "keep running and build again, whenever source folders or data files change")
+	watchInterval := flags.Duration("watch-interval", time.Second, "how often to look for changes, when watching")
+	watchSecrets := flags.Duration("watch-secrets", 0, "read secrets again in this interval and build, if they changed, when watching")
+	listMerge := flags.String("list-merge", string(builder.ListMergeReplace), "how to merge lists from data files: replace, append or merge-by-key")
+	listMergeKey := flags.String("list-merge-key", builder.DefaultListMergeKey, "key to identify maps in lists with merge-by-key")
+	var overrides []builder.DataOverride
+	flags.Var(overridesFlag{&overrides, builder.ParseSetOverride}, "set", "set path.to.key=value in the data, decimal numbers and true and false are typed (repeatable)")
+	flags.Var(overridesFlag{&overrides, builder.ParseSetJSONOverride}, "set-json", "set path.to.key=json in the data (repeatable)")
+	flags.Var(overridesFlag{&overrides, builder.ParseSetFileOverride}, "set-file", "set path.to.key to the contents of a file like path.to.key=path/to/file (repeatable)")
+	envPrefix := flags.String("env-prefix", "", "map environment variables like PREFIX_path__to__key=value into the data")
+	missingKey := flags.String("missingkey", string(builder.MissingKeyError), "what to do with keys, that are missing in the data: error, zero or report all of them after rendering with placeholders")
+	validate := flags.Bool("validate", false, "check, that rendered json, yaml and toml files parse and match the schemas mapped to them in .bobconfig files")
+	printData := flags.Bool("print-data", false, "print the merged data as yaml instead of building")
+	rollback := flags.Bool("rollback", false, "point the target folder at its latest kept generation instead of building")
 	buildUsage := func() {
 		fmt.Println(
 			"usage: ",
 			os.Args[0],
 			commandBuild,
+			"[ flags ]",
 			"path/to/source-folder-a",
 			"[ path/to/source-folder-b, ... ]",
-			"[ path/to/data-file.json | data-file.yaml ]",
+			"[ path/to/data-file.json | .yaml | .toml | .hcl | .env | .ini, ... ]",
 			"path/to/target/dir",
 		)
-		os.Exit(1)
+		fmt.Println("   or: ", os.Args[0], commandBuild, "[ flags ]", "[ --profile name ]", "target-in-project-file | --all")
+		fmt.Println("flags:")
+		flags.PrintDefaults()
+		exit(1)
 	}
-	if isHelpFlag(os.Args[2]) {
-		buildUsage()
+	flags.Usage = buildUsage
+	_ = flags.Parse(os.Args[2:])
+	useProject := *all || flags.NArg() == 1
+	if *rollback && !*all && flags.NArg() == 1 {
+		// a single folder is the target folder of a build without a project file
+		if info, err := os.Stat(flags.Arg(0)); err == nil && info.IsDir() {
+			useProject = false
+		}
 	}
-	builderArgs, err := builder.GetBuilderArgs(os.Args[2:])
-	if err != nil {
-		fmt.Println(err.Error())
-		buildUsage()
+	if *profile != "" && !useProject {
+		fmt.Println("profiles need a project file, name a target or use --all")
+		exit(1)
+	}
+	if *rollback && !useProject {
+		if flags.NArg() == 0 {
+			buildUsage()
+		}
+		err := builder.Rollback(flags.Arg(flags.NArg() - 1))
+		if err != nil {
+			fmt.Println("could not roll back:", err.Error())
+			exit(1)
+		}
+		return
+	}
+	var jobs []*buildJob
+	if useProject {
+		var err error
+		jobs, err = getProjectBuildJobs(*projectFile, *profile, flags.Args(), *all)
+		if err != nil {
+			fmt.Println(err.Error())
+			exit(1)
+		}
 	} else {
-		result, err := builder.Build(builderArgs)
+		builderArgs, err := builder.GetBuilderArgs(flags.Args())
 		if err != nil {
-			fmt.Println("a build error has occurred:", err.Error())
-			os.Exit(1)
+			fmt.Println(err.Error())
+			buildUsage()
+		}
+		jobs = []*buildJob{{args: builderArgs, writeOptions: builder.WriteOptions{Keep: 1}}}
+	}
+	// flags, that have been set explicitly, win over the settings of project targets
+	setFlags := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) {
+		setFlags[f.Name] = true
+	})
+	isSet := func(name string) bool {
+		return !useProject || setFlags[name]
+	}
+	for _, job := range jobs {
+		if isSet("list-merge") {
+			job.args.ListMerge = builder.ListMergeStrategy(*listMerge)
+		}
+		if isSet("list-merge-key") {
+			job.args.ListMergeKey = *listMergeKey
+		}
+		if isSet("env-prefix") {
+			job.args.EnvPrefix = *envPrefix
+		}
+		if isSet("missingkey") {
+			job.args.MissingKey = builder.MissingKeyPolicy(*missingKey)
+		}
+		if isSet("validate") {
+			job.args.Validate = *validate
+		}
+		if isSet("manifest") {
+			job.manifest = *manifest
 		}
-		writeError := builder.WriteProcessingResult(builderArgs.TargetFolder, result)
+		if isSet("plan") {
+			job.plan = *plan
+		}
+		if isSet("prune") {
+			job.writeOptions.Prune = *prune
+		}
+		if isSet("atomic") {
+			job.writeOptions.Atomic = *atomic
+		}
+		if isSet("keep") {
+			job.writeOptions.Keep = *keep
+		}
+		if job.writeOptions.Keep < 1 {
+			fmt.Println("keep has to be at least 1, got", job.writeOptions.Keep)
+			exit(1)
+		}
+		job.writeOptions.Protected = append(job.writeOptions.Protected, protected...)
+		job.args.Overrides = append(job.args.Overrides, overrides...)
+	}
+	if *rollback {
+		for _, job := range jobs {
+			err := builder.Rollback(job.args.TargetFolder)
+			if err != nil {
+				fmt.Println("could not roll back", job.name+":", err.Error())
+				exit(1)
+			}
+		}
+		return
+	}
+	if *printData {
+		for _, job := range jobs {
+			if len(jobs) > 1 {
+				fmt.Println("# " + job.name)
+			}
+			err := job.secrets.Apply()
+			if err == nil {
+				var data interface{}
+				data, err = builder.ReadData(job.args)
+				if err == nil {
+					var dataBytes []byte
+					dataBytes, err = yaml.Marshal(data)
+					fmt.Print(string(dataBytes))
+				}
+			}
+			if err != nil {
+				fmt.Println("could not read data:", err.Error())
+				exit(1)
+			}
+		}
+		return
+	}
+	handleResult := func(job *buildJob, result *builder.ProcessingResult) error {
+		if job.manifest != "" {
+			buildManifest, err := builder.NewManifest(job.args, result)
+			if err == nil {
+				err = result.AddManifest(job.manifest, buildManifest)
+			}
+			if err != nil {
+				return errors.New("could not create manifest: " + err.Error())
+			}
+		}
+		if job.plan {
+			buildPlan, err := builder.GetPlan(job.args.TargetFolder, result)
+			if err != nil {
+				return errors.New("could not plan processing result: " + err.Error())
+			}
+			if job.writeOptions.Prune {
+				err = buildPlan.IncludePrune(result, job.writeOptions.Protected)
+				if err != nil {
+					return errors.New("could not plan pruning of the target folder: " + err.Error())
+				}
+			}
+			buildPlan.Print(os.Stdout)
+			return nil
+		}
+		writeError := builder.Write(job.args.TargetFolder, result, job.writeOptions)
 		if writeError != nil {
-			fmt.Println("could not write processing result to fs:", writeError.Error())
-			os.Exit(1)
+			return errors.New("could not write processing result to fs: " + writeError.Error())
+		}
+		return nil
+	}
+	if *watch {
+		if len(jobs) != 1 {
+			fmt.Println("i can only watch a single target")
+			exit(1)
+		}
+		job := jobs[0]
+		err := job.secrets.Apply()
+		if err != nil {
+			fmt.Println("could not configure secrets:", err.Error())
+			exit(1)
+		}
+		stopsOnSignal.Store(true)
+		err = builder.Watch(job.args, builder.WatchOptions{
+			Interval:        *watchInterval,
+			SecretsInterval: *watchSecrets,
+		}, func(result *builder.ProcessingResult) error {
+			return handleResult(job, result)
+		}, interrupted)
+		if err != nil {
+			fmt.Println(err.Error())
+			exit(1)
+		}
+		return
+	}
+	var failed []string
+	for _, job := range jobs {
+		if job.name != "" {
+			fmt.Println("building target", job.name)
+		}
+		err := job.secrets.Apply()
+		if err != nil {
+			fmt.Println("could not configure secrets:", err.Error())
+			failed = append(failed, job.name)
+			continue
+		}
+		result, err := builder.Build(job.args)
+		if err != nil {
+			fmt.Println("a build error has occurred:", err.Error())
+			failed = append(failed, job.name)
+			continue
+		}
+		err = handleResult(job, result)
+		if err != nil {
+			fmt.Println(err.Error())
+			failed = append(failed, job.name)
 		}
 	}
+	if len(failed) > 0 {
+		if len(jobs) > 1 {
+			fmt.Println("failed targets:", strings.Join(failed, ", "))
+		}
+		exit(1)
+	}
 }
 
-func main() {
+func lintCommand() {
+	flags := flag.NewFlagSet(commandLint, flag.ExitOnError)
+	listMerge := flags.String("list-merge", string(builder.ListMergeReplace), "how to merge lists from data files: replace, append or merge-by-key")
+	listMergeKey := flags.String("list-merge-key", builder.DefaultListMergeKey, "key to identify maps in lists with merge-by-key")
+	var overrides []builder.DataOverride
+	flags.Var(overridesFlag{&overrides, builder.ParseSetOverride}, "set", "set path.to.key=value in the data, decimal numbers and true and false are typed (repeatable)")
+	flags.Var(overridesFlag{&overrides, builder.ParseSetJSONOverride}, "set-json", "set path.to.key=json in the data (repeatable)")
+	flags.Var(overridesFlag{&overrides, builder.ParseSetFileOverride}, "set-file", "set path.to.key to the contents of a file like path.to.key=path/to/file (repeatable)")
+	envPrefix := flags.String("env-prefix", "", "map environment variables like PREFIX_path__to__key=value into the data")
+	lintUsage := func() {
+		fmt.Println(
+			"usage: ",
+			os.Args[0],
+			commandLint,
+			"[ flags ]",
+			"path/to/source-folder-a",
+			"[ path/to/source-folder-b, ... ]",
+			"[ path/to/data-file.json | .yaml | .toml | .hcl | .env | .ini, ... ]",
+		)
+		fmt.Println("flags:")
+		flags.PrintDefaults()
+		exit(1)
+	}
+	flags.Usage = lintUsage
+	_ = flags.Parse(os.Args[2:])
+	lintArgs, err := builder.GetLintArgs(flags.Args())
+	if err != nil {
+		fmt.Println(err.Error())
+		lintUsage()
+	}
+	lintArgs.ListMerge = builder.ListMergeStrategy(*listMerge)
+	lintArgs.ListMergeKey = *listMergeKey
+	lintArgs.Overrides = overrides
+	lintArgs.EnvPrefix = *envPrefix
+	findings, err := builder.Lint(lintArgs)
+	if err != nil {
+		fmt.Println("could not lint:", err.Error())
+		exit(1)
+	}
+	for _, finding := range findings {
+		fmt.Println(finding)
+	}
+	if len(findings) > 0 {
+		fmt.Println(len(findings), "problems found")
+		exit(1)
+	}
+}
 
+func main() {
+	handleSignals()
 	if len(os.Args) > 1 {
 		switch os.Args[1] {
 		case commandVersion:
@@ -316,6 +685,8 @@ func main() {
 			vaultLocalCommand()
 		case commandBuild:
 			buildCommand()
+		case commandLint:
+			lintCommand()
 		default:
 			fmt.Println("unknown command", "\""+os.Args[1]+"\"")
 			help()
@@ -323,4 +694,5 @@ func main() {
 	} else {
 		help()
 	}
+	exit(0)
 }
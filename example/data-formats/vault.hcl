@@ -0,0 +1,7 @@
+backend "file" {
+  path = "db"
+}
+listener "tcp" {
+  address = "127.0.0.1:8200"
+  tls_disable = 1
+}
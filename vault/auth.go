@@ -0,0 +1,268 @@
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultKubernetesJWTFile is where kubernetes mounts the token of the service account of a pod
+const DefaultKubernetesJWTFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// AuthInfo is the auth part of the response of a login or a renewal
+type AuthInfo struct {
+	ClientToken string `json:"client_token"`
+	// LeaseDuration in seconds, tokens without a lease do not expire
+	LeaseDuration int  `json:"lease_duration"`
+	Renewable     bool `json:"renewable"`
+}
+
+// Auth logs in to vault with an auth method
+type Auth interface {
+	Login(c *Client) (*AuthInfo, error)
+}
+
+// AppRoleAuth logs in with a role id and a secret id
+type AppRoleAuth struct {
+	// Mount defaults to approle
+	Mount    string
+	RoleID   string
+	SecretID string
+}
+
+// Login with the role
+func (a *AppRoleAuth) Login(c *Client) (*AuthInfo, error) {
+	body := map[string]string{"role_id": a.RoleID}
+	if a.SecretID != "" {
+		body["secret_id"] = a.SecretID
+	}
+	return c.AuthLogin(getAuthMount(a.Mount, "approle")+"/login", body)
+}
+
+// KubernetesAuth logs in with the jwt of a kubernetes service account
+type KubernetesAuth struct {
+	// Mount defaults to kubernetes
+	Mount string
+	Role  string
+	JWT   string
+	// JWTFile is read on every login instead of using JWT, because kubernetes rotates the token
+	JWTFile string
+}
+
+// Login with the service account
+func (a *KubernetesAuth) Login(c *Client) (*AuthInfo, error) {
+	jwt := a.JWT
+	if a.JWTFile != "" {
+		var err error
+		jwt, err = readSecretFile(a.JWTFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c.AuthLogin(getAuthMount(a.Mount, "kubernetes")+"/login", map[string]string{"role": a.Role, "jwt": jwt})
+}
+
+// UserpassAuth logs in with a username and a password
+type UserpassAuth struct {
+	// Mount defaults to userpass
+	Mount    string
+	Username string
+	Password string
+}
+
+// Login with the user
+func (a *UserpassAuth) Login(c *Client) (*AuthInfo, error) {
+	return c.AuthLogin(getAuthMount(a.Mount, "userpass")+"/login/"+a.Username, map[string]string{"password": a.Password})
+}
+
+func getAuthMount(mount, defaultMount string) string {
+	if mount == "" {
+		return defaultMount
+	}
+	return strings.Trim(mount, "/")
+}
+
+// AuthFromEnv configures the auth method in VAULT_AUTH_METHOD with an optional VAULT_AUTH_MOUNT:
+//
+//	approle     VAULT_ROLE_ID and VAULT_SECRET_ID
+//	kubernetes  VAULT_K8S_ROLE and VAULT_K8S_JWT_FILE, which defaults to DefaultKubernetesJWTFile
+//	userpass    VAULT_USERNAME and VAULT_PASSWORD
+//
+// VAULT_ROLE_ID, VAULT_SECRET_ID and VAULT_PASSWORD can be read from files with a _FILE suffix. Without a method
+// nil is returned and VAULT_TOKEN is used as it is.
+func AuthFromEnv() (Auth, error) {
+	return authFromEnv(os.Getenv)
+}
+
+func authFromEnv(getenv func(name string) string) (Auth, error) {
+	mount := getenv("VAULT_AUTH_MOUNT")
+	switch method := getenv("VAULT_AUTH_METHOD"); method {
+	case "", "token":
+		return nil, nil
+	case "approle":
+		roleID, err := getEnvOrFile(getenv, "VAULT_ROLE_ID")
+		if err != nil {
+			return nil, err
+		}
+		if roleID == "" {
+			return nil, errors.New("approle auth needs VAULT_ROLE_ID or VAULT_ROLE_ID_FILE")
+		}
+		secretID, err := getEnvOrFile(getenv, "VAULT_SECRET_ID")
+		if err != nil {
+			return nil, err
+		}
+		return &AppRoleAuth{Mount: mount, RoleID: roleID, SecretID: secretID}, nil
+	case "kubernetes":
+		role := getenv("VAULT_K8S_ROLE")
+		if role == "" {
+			return nil, errors.New("kubernetes auth needs VAULT_K8S_ROLE")
+		}
+		jwtFile := getenv("VAULT_K8S_JWT_FILE")
+		if jwtFile == "" {
+			jwtFile = DefaultKubernetesJWTFile
+		}
+		// fail early, the file is read again on every login
+		_, err := readSecretFile(jwtFile)
+		if err != nil {
+			return nil, err
+		}
+		return &KubernetesAuth{Mount: mount, Role: role, JWTFile: jwtFile}, nil
+	case "userpass":
+		username := getenv("VAULT_USERNAME")
+		password, err := getEnvOrFile(getenv, "VAULT_PASSWORD")
+		if err != nil {
+			return nil, err
+		}
+		if username == "" || password == "" {
+			return nil, errors.New("userpass auth needs VAULT_USERNAME and VAULT_PASSWORD or VAULT_PASSWORD_FILE")
+		}
+		return &UserpassAuth{Mount: mount, Username: username, Password: password}, nil
+	default:
+		return nil, fmt.Errorf("unknown VAULT_AUTH_METHOD %q, use approle, kubernetes, userpass or token", method)
+	}
+}
+
+// getEnvOrFile reads the environment variable name or the file in name_FILE
+func getEnvOrFile(getenv func(name string) string, name string) (string, error) {
+	if value := getenv(name); value != "" {
+		return value, nil
+	}
+	if filename := getenv(name + "_FILE"); filename != "" {
+		return readSecretFile(filename)
+	}
+	return "", nil
+}
+
+func readSecretFile(filename string) (string, error) {
+	secretBytes, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(secretBytes)), nil
+}
+
+// AuthLogin posts to the login endpoint auth/path without a token
+func (c *Client) AuthLogin(path string, body interface{}) (*AuthInfo, error) {
+	response := &struct {
+		Auth *AuthInfo `json:"auth"`
+	}{}
+	err := c.doWithToken("", http.MethodPost, "auth/"+path, body, response)
+	if err != nil {
+		return nil, err
+	}
+	if response.Auth == nil || response.Auth.ClientToken == "" {
+		return nil, fmt.Errorf("login with auth/%s returned no token", path)
+	}
+	return response.Auth, nil
+}
+
+// Login acquires a token with auth and keeps renewing it in the background until Logout. Tokens, that can not be
+// renewed any more, are replaced by logging in again. Logging in again logs out first.
+func (c *Client) Login(auth Auth) error {
+	_ = c.Logout()
+	info, err := auth.Login(c)
+	if err != nil {
+		return errors.New("could not log in to vault: " + err.Error())
+	}
+	c.setToken(info.ClientToken)
+	c.auth = auth
+	c.stopRenewal = make(chan struct{})
+	c.renewalDone = make(chan struct{})
+	go c.renew(info)
+	return nil
+}
+
+// Logout stops renewing the token and revokes it, if it has been acquired with Login. Callers may ignore the error, a
+// token, that can not be revoked, expires anyway.
+func (c *Client) Logout() error {
+	if c.auth == nil {
+		return nil
+	}
+	close(c.stopRenewal)
+	<-c.renewalDone
+	c.auth = nil
+	err := c.do(http.MethodPost, "auth/token/revoke-self", nil, nil)
+	c.setToken("")
+	if err != nil {
+		return errors.New("could not revoke vault token: " + err.Error())
+	}
+	return nil
+}
+
+// getRenewalWait renews after two thirds of the lease
+func getRenewalWait(info *AuthInfo) time.Duration {
+	return time.Duration(info.LeaseDuration) * time.Second * 2 / 3
+}
+
+func (c *Client) renew(info *AuthInfo) {
+	defer close(c.renewalDone)
+	wait := getRenewalWait(info)
+	for wait > 0 {
+		select {
+		case <-c.stopRenewal:
+			return
+		case <-time.After(wait):
+		}
+		renewed, err := c.renewToken(info)
+		if err != nil {
+			fmt.Println("could not renew vault token:", err.Error())
+			// try again before the token expires
+			if wait = wait / 4; wait < time.Second {
+				wait = time.Second
+			}
+			continue
+		}
+		info = renewed
+		wait = getRenewalWait(info)
+	}
+}
+
+// renewToken renews the token or logs in again, when it can not be renewed or its lease has been cut short by its
+// maximum ttl
+func (c *Client) renewToken(info *AuthInfo) (*AuthInfo, error) {
+	if info.Renewable {
+		response := &struct {
+			Auth *AuthInfo `json:"auth"`
+		}{}
+		err := c.do(http.MethodPost, "auth/token/renew-self", map[string]string{}, response)
+		if err == nil && response.Auth != nil && response.Auth.LeaseDuration >= info.LeaseDuration {
+			return response.Auth, nil
+		}
+	}
+	renewed, err := c.auth.Login(c)
+	if err != nil {
+		return nil, err
+	}
+	previous := c.getToken()
+	c.setToken(renewed.ClientToken)
+	err = c.doWithToken(previous, http.MethodPost, "auth/token/revoke-self", nil, nil)
+	if err != nil {
+		// the previous token expires soon anyway
+		fmt.Println("could not revoke the previous vault token:", err.Error())
+	}
+	return renewed, nil
+}
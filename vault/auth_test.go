@@ -0,0 +1,197 @@
+package vault
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestClientLogin(t *testing.T) {
+	for name, auth := range map[string]Auth{
+		"approle":    &AppRoleAuth{RoleID: "role", SecretID: "secret"},
+		"kubernetes": &KubernetesAuth{Role: "app", JWT: "jwt"},
+		"userpass":   &UserpassAuth{Username: "bob", Password: "pw"},
+		"mount":      &UserpassAuth{Mount: "ldap-users/", Username: "bob", Password: "pw"},
+	} {
+		v, client := newTestVault(t, map[string]map[string]interface{}{"secret/app": {"user": "app"}})
+		client.Token = ""
+		if err := client.Login(auth); err != nil {
+			t.Fatal(name, err)
+		}
+		if client.Token != "login-token-1" {
+			t.Error(name, "unexpected token", client.Token)
+		}
+		if _, err := client.Read("secret/app"); err != nil {
+			t.Error(name, err)
+		}
+		if err := client.Logout(); err != nil {
+			t.Error(name, err)
+		}
+		if !reflect.DeepEqual(v.revoked, []string{"login-token-1"}) || client.Token != "" {
+			t.Error(name, "token has not been revoked", v.revoked, client.Token)
+		}
+	}
+
+	v, client := newTestVault(t, nil)
+	auth := &AppRoleAuth{RoleID: "role", SecretID: "secret"}
+	if err := client.Login(auth); err != nil {
+		t.Fatal(err)
+	}
+	// logging in again logs out first
+	if err := client.Login(auth); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v.revoked, []string{"login-token-1"}) || client.Token != "login-token-2" {
+		t.Error("the first token has not been revoked", v.revoked, client.Token)
+	}
+	if err := client.Logout(); err != nil {
+		t.Error(err)
+	}
+
+	// the jwt file is read on every login, kubernetes rotates the token
+	_, client = newTestVault(t, nil)
+	folder, err := ioutil.TempDir(os.TempDir(), "vault-auth-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+	jwtFile := filepath.Join(folder, "jwt")
+	if err := ioutil.WriteFile(jwtFile, []byte("expired"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	kubernetesAuth := &KubernetesAuth{Role: "app", JWTFile: jwtFile}
+	if err := client.Login(kubernetesAuth); err == nil {
+		t.Error("expected an error for an expired jwt")
+	}
+	if err := ioutil.WriteFile(jwtFile, []byte("jwt\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Login(kubernetesAuth); err != nil {
+		t.Error("the rotated jwt has not been read", err)
+	}
+	if err := client.Logout(); err != nil {
+		t.Error(err)
+	}
+
+	_, client = newTestVault(t, nil)
+	if err := client.Login(&UserpassAuth{Username: "bob", Password: "wrong"}); err == nil {
+		t.Error("expected an error for wrong credentials")
+	}
+	if err := client.Logout(); err != nil {
+		t.Error("logging out without logging in should do nothing", err)
+	}
+}
+
+func TestClientRenewal(t *testing.T) {
+	v, client := newTestVault(t, nil)
+	v.leaseDuration, v.renewable = 1, true
+	if err := client.Login(&AppRoleAuth{RoleID: "role", SecretID: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor := func(condition func() bool) bool {
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); {
+			v.lock.Lock()
+			done := condition()
+			v.lock.Unlock()
+			if done {
+				return true
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		return false
+	}
+	if !waitFor(func() bool { return v.renewals > 0 }) {
+		t.Fatal("token has not been renewed")
+	}
+	// tokens, that can not be renewed, are replaced by logging in again
+	v.lock.Lock()
+	v.renewable = false
+	v.lock.Unlock()
+	if !waitFor(func() bool { return v.logins > 2 }) {
+		t.Fatal("did not log in again")
+	}
+	if err := client.Logout(); err != nil {
+		t.Error(err)
+	}
+	// every token is revoked, the replaced ones after logging in again and the latest one by Logout
+	expected := []string{}
+	for i := 1; i <= v.logins; i++ {
+		expected = append(expected, "login-token-"+strconv.Itoa(i))
+	}
+	if !reflect.DeepEqual(v.revoked, expected) {
+		t.Error("unexpected revoked tokens", v.revoked, v.logins)
+	}
+}
+
+func TestAuthFromEnv(t *testing.T) {
+	names := []string{
+		"VAULT_AUTH_METHOD", "VAULT_AUTH_MOUNT", "VAULT_ROLE_ID", "VAULT_ROLE_ID_FILE", "VAULT_SECRET_ID",
+		"VAULT_SECRET_ID_FILE", "VAULT_K8S_ROLE", "VAULT_K8S_JWT_FILE", "VAULT_USERNAME", "VAULT_PASSWORD",
+		"VAULT_PASSWORD_FILE",
+	}
+	reset := func() {
+		for _, name := range names {
+			_ = os.Unsetenv(name)
+		}
+	}
+	for _, name := range names {
+		value, ok := os.LookupEnv(name)
+		name := name
+		t.Cleanup(func() {
+			if ok {
+				_ = os.Setenv(name, value)
+			} else {
+				_ = os.Unsetenv(name)
+			}
+		})
+	}
+	folder, err := ioutil.TempDir(os.TempDir(), "vault-auth-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+	secretIDFile := filepath.Join(folder, "secret-id")
+	jwtFile := filepath.Join(folder, "jwt")
+	if err := ioutil.WriteFile(secretIDFile, []byte("secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(jwtFile, []byte("jwt"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    Auth
+		wantErr bool
+	}{
+		{"token", map[string]string{}, nil, false},
+		{"approle", map[string]string{"VAULT_AUTH_METHOD": "approle", "VAULT_ROLE_ID": "role", "VAULT_SECRET_ID_FILE": secretIDFile, "VAULT_AUTH_MOUNT": "ci"}, &AppRoleAuth{Mount: "ci", RoleID: "role", SecretID: "secret"}, false},
+		{"approle without role", map[string]string{"VAULT_AUTH_METHOD": "approle"}, nil, true},
+		{"kubernetes", map[string]string{"VAULT_AUTH_METHOD": "kubernetes", "VAULT_K8S_ROLE": "app", "VAULT_K8S_JWT_FILE": jwtFile}, &KubernetesAuth{Role: "app", JWTFile: jwtFile}, false},
+		{"kubernetes without jwt", map[string]string{"VAULT_AUTH_METHOD": "kubernetes", "VAULT_K8S_ROLE": "app", "VAULT_K8S_JWT_FILE": filepath.Join(folder, "missing")}, nil, true},
+		{"userpass", map[string]string{"VAULT_AUTH_METHOD": "userpass", "VAULT_USERNAME": "bob", "VAULT_PASSWORD": "pw"}, &UserpassAuth{Username: "bob", Password: "pw"}, false},
+		{"userpass without password", map[string]string{"VAULT_AUTH_METHOD": "userpass", "VAULT_USERNAME": "bob"}, nil, true},
+		{"unknown", map[string]string{"VAULT_AUTH_METHOD": "github"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reset()
+			for name, value := range tt.env {
+				_ = os.Setenv(name, value)
+			}
+			got, err := AuthFromEnv()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("AuthFromEnv() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("AuthFromEnv() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
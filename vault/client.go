@@ -0,0 +1,339 @@
+package vault
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultAddress is used, when VAULT_ADDR is not set, like the vault command line program does
+const DefaultAddress = "https://127.0.0.1:8200"
+
+var (
+	// ErrForbidden is returned for 403 responses, the token is missing, invalid or not allowed to access a path
+	ErrForbidden = errors.New("permission denied")
+	// ErrNotFound is returned for 404 responses
+	ErrNotFound = errors.New("not found")
+	// ErrSealed is returned for 503 responses, which vault sends, while it is sealed
+	ErrSealed = errors.New("vault is sealed")
+)
+
+// ResponseError is returned for responses with an error status, use errors.Is with ErrForbidden, ErrNotFound and
+// ErrSealed to check for the common ones
+type ResponseError struct {
+	Method     string
+	Path       string
+	StatusCode int
+	// Errors vault has sent
+	Errors []string
+}
+
+func (e *ResponseError) Error() string {
+	message := fmt.Sprintf("%s %s: %d %s", e.Method, e.Path, e.StatusCode, http.StatusText(e.StatusCode))
+	if len(e.Errors) > 0 {
+		message += ": " + strings.Join(e.Errors, ", ")
+	}
+	return message
+}
+
+// Is maps status codes to ErrForbidden, ErrNotFound and ErrSealed
+func (e *ResponseError) Is(target error) bool {
+	switch target {
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrSealed:
+		return e.StatusCode == http.StatusServiceUnavailable
+	}
+	return false
+}
+
+// Client talks to the HTTP API of vault and handles the paths of kv version 1 and 2 mounts alike
+type Client struct {
+	// Address like https://vault.example.com:8200
+	Address   string
+	Token     string
+	Namespace string
+	HTTP      *http.Client
+
+	mountsLock sync.Mutex
+	mounts     map[string]*mount
+
+	// tokenLock guards Token, while it is renewed in the background after Login
+	tokenLock   sync.RWMutex
+	auth        Auth
+	stopRenewal chan struct{}
+	renewalDone chan struct{}
+}
+
+// NewClientFromEnv configures a client with VAULT_ADDR, VAULT_TOKEN, VAULT_NAMESPACE, VAULT_CACERT, VAULT_CAPATH and
+// VAULT_SKIP_VERIFY like the vault command line program
+func NewClientFromEnv() (*Client, error) {
+	return newClient(os.Getenv)
+}
+
+func newClient(getenv func(name string) string) (*Client, error) {
+	address := getenv("VAULT_ADDR")
+	if address == "" {
+		address = DefaultAddress
+	}
+	_, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAULT_ADDR %q: %q", address, err)
+	}
+	tlsConfig := &tls.Config{}
+	if skipVerify := getenv("VAULT_SKIP_VERIFY"); skipVerify != "" {
+		tlsConfig.InsecureSkipVerify, err = strconv.ParseBool(skipVerify)
+		if err != nil {
+			return nil, fmt.Errorf("invalid VAULT_SKIP_VERIFY %q: %q", skipVerify, err)
+		}
+	}
+	caFiles := []string{}
+	if caCert := getenv("VAULT_CACERT"); caCert != "" {
+		caFiles = append(caFiles, caCert)
+	}
+	if caPath := getenv("VAULT_CAPATH"); caPath != "" {
+		pemFiles, err := filepath.Glob(filepath.Join(caPath, "*.pem"))
+		if err != nil {
+			return nil, err
+		}
+		caFiles = append(caFiles, pemFiles...)
+	}
+	if len(caFiles) > 0 {
+		tlsConfig.RootCAs = x509.NewCertPool()
+		for _, caFile := range caFiles {
+			pemBytes, err := ioutil.ReadFile(caFile)
+			if err != nil {
+				return nil, errors.New("could not read ca certificate: " + err.Error())
+			}
+			if !tlsConfig.RootCAs.AppendCertsFromPEM(pemBytes) {
+				return nil, fmt.Errorf("no certificates found in %q", caFile)
+			}
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &Client{
+		Address:   strings.TrimSuffix(address, "/"),
+		Token:     getenv("VAULT_TOKEN"),
+		Namespace: getenv("VAULT_NAMESPACE"),
+		HTTP:      &http.Client{Transport: transport, Timeout: 30 * time.Second},
+	}, nil
+}
+
+var (
+	defaultClientLock = &sync.Mutex{}
+	defaultClient     *Client
+	// defaultEnv has values, that the default client uses instead of environment variables
+	defaultEnv map[string]string
+)
+
+// DefaultClient is used by Read, Tree and Unseal and configured from the environment and the values of
+// SetDefaultEnv, when it is used first. When VAULT_AUTH_METHOD is set, it logs in and keeps its token renewed until
+// it is replaced or closed.
+func DefaultClient() (*Client, error) {
+	defaultClientLock.Lock()
+	defer defaultClientLock.Unlock()
+	if defaultClient == nil {
+		getenv := func(name string) string {
+			if value, ok := defaultEnv[name]; ok {
+				return value
+			}
+			return os.Getenv(name)
+		}
+		client, err := newClient(getenv)
+		if err != nil {
+			return nil, err
+		}
+		auth, err := authFromEnv(getenv)
+		if err != nil {
+			return nil, err
+		}
+		if auth != nil {
+			err = client.Login(auth)
+			if err != nil {
+				return nil, err
+			}
+		}
+		defaultClient = client
+	}
+	return defaultClient, nil
+}
+
+// SetDefaultClient replaces the default client, nil configures it from the environment again, when it is used next.
+// The token of the replaced client is revoked, if it has logged in.
+func SetDefaultClient(client *Client) {
+	defaultClientLock.Lock()
+	defer defaultClientLock.Unlock()
+	if defaultClient != nil && defaultClient != client {
+		_ = defaultClient.Logout()
+	}
+	defaultClient = client
+}
+
+// SetDefaultEnv sets values for environment variables like VAULT_ADDR, that the default client uses instead of the
+// environment, without changing the environment of the process. nil uses the environment alone. The default client
+// is replaced and configured again, when it is used next.
+func SetDefaultEnv(env map[string]string) {
+	defaultClientLock.Lock()
+	defer defaultClientLock.Unlock()
+	if defaultClient != nil {
+		_ = defaultClient.Logout()
+	}
+	defaultClient = nil
+	defaultEnv = map[string]string{}
+	for name, value := range env {
+		defaultEnv[name] = value
+	}
+}
+
+// CloseDefaultClient revokes the token of the default client, if it has logged in
+func CloseDefaultClient() error {
+	defaultClientLock.Lock()
+	defer defaultClientLock.Unlock()
+	if defaultClient == nil {
+		return nil
+	}
+	err := defaultClient.Logout()
+	defaultClient = nil
+	return err
+}
+
+func (c *Client) getToken() string {
+	c.tokenLock.RLock()
+	defer c.tokenLock.RUnlock()
+	return c.Token
+}
+
+func (c *Client) setToken(token string) {
+	c.tokenLock.Lock()
+	defer c.tokenLock.Unlock()
+	c.Token = token
+}
+
+// do sends a request with the token of the client to /v1/path and decodes the json response into result, if it is
+// not nil
+func (c *Client) do(method, path string, body, result interface{}) error {
+	return c.doWithToken(c.getToken(), method, path, body, result)
+}
+
+func (c *Client) doWithToken(token, method, path string, body, result interface{}) error {
+	var bodyReader io.Reader
+	if body != nil {
+		bodyBytes, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		bodyReader = bytes.NewReader(bodyBytes)
+	}
+	path = strings.Trim(path, "/")
+	request, err := http.NewRequest(method, c.Address+"/v1/"+path, bodyReader)
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		request.Header.Set("X-Vault-Token", token)
+	}
+	if c.Namespace != "" {
+		request.Header.Set("X-Vault-Namespace", c.Namespace)
+	}
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	httpClient := c.HTTP
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= 400 {
+		responseError := &ResponseError{Method: method, Path: path, StatusCode: response.StatusCode}
+		errorResponse := struct {
+			Errors []string `json:"errors"`
+		}{}
+		if json.NewDecoder(response.Body).Decode(&errorResponse) == nil {
+			responseError.Errors = errorResponse.Errors
+		}
+		return responseError
+	}
+	if result == nil || response.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	decoder := json.NewDecoder(response.Body)
+	// numbers in secrets are turned into ints and floats by normalizeNumbers
+	decoder.UseNumber()
+	err = decoder.Decode(result)
+	if err != nil {
+		return fmt.Errorf("could not decode response of %s %s: %q", method, path, err)
+	}
+	return nil
+}
+
+// Read the latest data of a secret
+func (c *Client) Read(path string) (secret map[string]interface{}, err error) {
+	return c.ReadVersion(path, 0)
+}
+
+// List the keys under a path, folders end with a slash and there are no keys for paths, that do not exist
+func (c *Client) List(path string) (keys []string, err error) {
+	response := &struct {
+		Data struct {
+			Keys []string `json:"keys"`
+		} `json:"data"`
+	}{}
+	err = c.do("LIST", c.getMount(path).apiPath(path, "metadata"), nil, response)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return response.Data.Keys, nil
+}
+
+// SealStatus of a vault
+type SealStatus struct {
+	Sealed bool `json:"sealed"`
+	// T is the number of keys, that are needed to unseal
+	T int `json:"t"`
+	// N is the number of keys
+	N int `json:"n"`
+	// Progress is the number of keys, that have been entered
+	Progress int `json:"progress"`
+}
+
+// Unseal enters a key
+func (c *Client) Unseal(key string) (status *SealStatus, err error) {
+	status = &SealStatus{}
+	err = c.do(http.MethodPut, "sys/unseal", map[string]string{"key": key}, status)
+	if err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// Unseal enters a key with the default client
+func Unseal(key string) (status *SealStatus, err error) {
+	client, err := DefaultClient()
+	if err != nil {
+		return nil, err
+	}
+	return client.Unseal(key)
+}
@@ -0,0 +1,512 @@
+package vault
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const testToken = "test-token"
+
+// testVault is a stand-in for the HTTP API of vault with secrets by path
+type testVault struct {
+	lock    sync.Mutex
+	secrets map[string]map[string]interface{}
+	// mounts map the paths of kv mounts to their version, without mounts there is no mount information at all
+	mounts map[string]int
+	// versions are the secrets of kv version 2 mounts by their path without the data segment
+	versions  map[string][]map[string]interface{}
+	sealed    bool
+	unsealKey string
+	progress  int
+	// tokens, that have been issued by logins and not been revoked, are valid next to testToken
+	tokens        map[string]bool
+	leaseDuration int
+	renewable     bool
+	logins        int
+	renewals      int
+	revoked       []string
+	// namespaces are recorded for every request
+	namespaces []string
+}
+
+func newTestVault(t *testing.T, secrets map[string]map[string]interface{}) (*testVault, *Client) {
+	v := &testVault{secrets: secrets}
+	server := httptest.NewServer(v)
+	t.Cleanup(server.Close)
+	return v, &Client{Address: server.URL, Token: testToken, HTTP: server.Client()}
+}
+
+func (v *testVault) writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+func (v *testVault) writeErrors(w http.ResponseWriter, status int, errs ...string) {
+	v.writeJSON(w, status, map[string][]string{"errors": errs})
+}
+
+func (v *testVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	v.namespaces = append(v.namespaces, r.Header.Get("X-Vault-Namespace"))
+	path := strings.TrimPrefix(r.URL.Path, "/v1/")
+	if path == "sys/unseal" && r.Method == http.MethodPut {
+		body := map[string]string{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body["key"] != v.unsealKey {
+			v.writeErrors(w, http.StatusBadRequest, "invalid key")
+			return
+		}
+		v.progress++
+		v.sealed = v.progress < 2
+		v.writeJSON(w, http.StatusOK, &SealStatus{Sealed: v.sealed, T: 2, N: 3, Progress: v.progress % 2})
+		return
+	}
+	if v.sealed {
+		v.writeErrors(w, http.StatusServiceUnavailable, "Vault is sealed")
+		return
+	}
+	if strings.HasPrefix(path, "auth/") && !strings.HasPrefix(path, "auth/token/") {
+		v.serveLogin(w, r, path)
+		return
+	}
+	token := r.Header.Get("X-Vault-Token")
+	if token != testToken && !v.tokens[token] {
+		v.writeErrors(w, http.StatusForbidden, "permission denied")
+		return
+	}
+	switch path {
+	case "auth/token/renew-self":
+		v.renewals++
+		v.writeJSON(w, http.StatusOK, map[string]interface{}{"auth": &AuthInfo{ClientToken: token, LeaseDuration: v.leaseDuration, Renewable: v.renewable}})
+		return
+	case "auth/token/revoke-self":
+		delete(v.tokens, token)
+		v.revoked = append(v.revoked, token)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if strings.HasPrefix(path, "sys/internal/ui/mounts/") {
+		v.serveMount(w, strings.TrimPrefix(path, "sys/internal/ui/mounts/"))
+		return
+	}
+	for mountPath, version := range v.mounts {
+		if version == 2 && strings.HasPrefix(path, mountPath) {
+			v.serveKV2(w, r, mountPath, strings.TrimPrefix(path, mountPath))
+			return
+		}
+	}
+	switch r.Method {
+	case "LIST":
+		keys := map[string]bool{}
+		for p := range v.secrets {
+			if strings.HasPrefix(p, path+"/") {
+				key := strings.TrimPrefix(p, path+"/")
+				if i := strings.Index(key, "/"); i >= 0 {
+					key = key[:i+1]
+				}
+				keys[key] = true
+			}
+		}
+		if len(keys) == 0 {
+			v.writeErrors(w, http.StatusNotFound)
+			return
+		}
+		list := []string{}
+		for key := range keys {
+			list = append(list, key)
+		}
+		sort.Strings(list)
+		v.writeJSON(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{"keys": list}})
+	case http.MethodGet:
+		secret, ok := v.secrets[path]
+		if !ok {
+			v.writeErrors(w, http.StatusNotFound)
+			return
+		}
+		v.writeJSON(w, http.StatusOK, map[string]interface{}{"data": secret})
+	default:
+		v.writeErrors(w, http.StatusMethodNotAllowed)
+	}
+}
+
+func (v *testVault) serveLogin(w http.ResponseWriter, r *http.Request, path string) {
+	body := map[string]string{}
+	_ = json.NewDecoder(r.Body).Decode(&body)
+	valid := false
+	switch path {
+	case "auth/approle/login":
+		valid = body["role_id"] == "role" && body["secret_id"] == "secret"
+	case "auth/kubernetes/login":
+		valid = body["role"] == "app" && body["jwt"] == "jwt"
+	case "auth/userpass/login/bob", "auth/ldap-users/login/bob":
+		valid = body["password"] == "pw"
+	}
+	if !valid {
+		v.writeErrors(w, http.StatusBadRequest, "invalid credentials")
+		return
+	}
+	v.logins++
+	token := "login-token-" + strconv.Itoa(v.logins)
+	if v.tokens == nil {
+		v.tokens = map[string]bool{}
+	}
+	v.tokens[token] = true
+	v.writeJSON(w, http.StatusOK, map[string]interface{}{"auth": &AuthInfo{ClientToken: token, LeaseDuration: v.leaseDuration, Renewable: v.renewable}})
+}
+
+func (v *testVault) serveMount(w http.ResponseWriter, path string) {
+	for mountPath, version := range v.mounts {
+		if strings.HasPrefix(path+"/", mountPath) {
+			v.writeJSON(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{
+				"path":    mountPath,
+				"type":    "kv",
+				"options": map[string]string{"version": strconv.Itoa(version)},
+			}})
+			return
+		}
+	}
+	if v.mounts == nil {
+		v.writeErrors(w, http.StatusNotFound)
+		return
+	}
+	v.writeErrors(w, http.StatusForbidden, "preflight capability check returned 403")
+}
+
+func (v *testVault) serveKV2(w http.ResponseWriter, r *http.Request, mountPath, path string) {
+	switch {
+	case r.Method == "LIST" && strings.HasPrefix(path+"/", "metadata/"):
+		prefix := mountPath + strings.TrimPrefix(path+"/", "metadata/")
+		keys := map[string]bool{}
+		for p := range v.versions {
+			if strings.HasPrefix(p, prefix) {
+				key := strings.TrimPrefix(p, prefix)
+				if i := strings.Index(key, "/"); i >= 0 {
+					key = key[:i+1]
+				}
+				keys[key] = true
+			}
+		}
+		if len(keys) == 0 {
+			v.writeErrors(w, http.StatusNotFound)
+			return
+		}
+		list := []string{}
+		for key := range keys {
+			list = append(list, key)
+		}
+		sort.Strings(list)
+		v.writeJSON(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{"keys": list}})
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "data/"):
+		versions := v.versions[mountPath+strings.TrimPrefix(path, "data/")]
+		version := len(versions)
+		if query := r.URL.Query().Get("version"); query != "" {
+			version, _ = strconv.Atoi(query)
+		}
+		if version < 1 || version > len(versions) {
+			v.writeErrors(w, http.StatusNotFound)
+			return
+		}
+		v.writeJSON(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{
+			"data":     versions[version-1],
+			"metadata": map[string]int{"version": version},
+		}})
+	default:
+		v.writeErrors(w, http.StatusNotFound)
+	}
+}
+
+func TestClientRead(t *testing.T) {
+	v, client := newTestVault(t, map[string]map[string]interface{}{
+		"secret/app/db": {"user": "app", "password": "s3cret"},
+	})
+	client.Namespace = "team"
+	secret, err := client.Read("secret/app/db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(secret, map[string]interface{}{"user": "app", "password": "s3cret"}) {
+		t.Error("unexpected secret", secret)
+	}
+	if len(v.namespaces) == 0 || v.namespaces[0] != "team" || v.namespaces[len(v.namespaces)-1] != "team" {
+		t.Error("namespace was not sent", v.namespaces)
+	}
+
+	_, err = client.Read("secret/app/missing")
+	if !errors.Is(err, ErrNotFound) {
+		t.Error("expected not found, got", err)
+	}
+	client.Token = "wrong"
+	_, err = client.Read("secret/app/db")
+	if !errors.Is(err, ErrForbidden) {
+		t.Error("expected forbidden, got", err)
+	}
+	var responseError *ResponseError
+	if !errors.As(err, &responseError) || !reflect.DeepEqual(responseError.Errors, []string{"permission denied"}) {
+		t.Error("expected the errors of vault, got", err)
+	}
+	v.sealed = true
+	_, err = client.Read("secret/app/db")
+	if !errors.Is(err, ErrSealed) {
+		t.Error("expected sealed, got", err)
+	}
+}
+
+func TestClientTree(t *testing.T) {
+	_, client := newTestVault(t, map[string]map[string]interface{}{
+		"secret/a":       {"user": "a"},
+		"secret/b/c":     {"user": "c"},
+		"secret/b/d/e":   {"user": "e"},
+		"other/not-this": {"user": "nope"},
+	})
+	data, err := client.tree("secret/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]map[string]interface{}{
+		"secret/a":     {"user": "a"},
+		"secret/b/c":   {"user": "c"},
+		"secret/b/d/e": {"user": "e"},
+	}
+	if !reflect.DeepEqual(data, expected) {
+		t.Error("unexpected tree", data)
+	}
+	data, err = client.tree("empty")
+	if err != nil || len(data) != 0 {
+		t.Error("expected an empty tree", data, err)
+	}
+}
+
+func TestClientUnseal(t *testing.T) {
+	v, client := newTestVault(t, nil)
+	v.sealed, v.unsealKey = true, "key"
+	status, err := client.Unseal("key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Sealed || status.Progress != 1 || status.T != 2 {
+		t.Error("unexpected seal status", status)
+	}
+	status, err = client.Unseal("key")
+	if err != nil || status.Sealed {
+		t.Error("expected vault to be unsealed", status, err)
+	}
+	_, err = client.Unseal("wrong")
+	if err == nil {
+		t.Error("expected an error for a wrong key")
+	}
+}
+
+func TestNewClientFromEnv(t *testing.T) {
+	for _, name := range []string{"VAULT_ADDR", "VAULT_TOKEN", "VAULT_NAMESPACE", "VAULT_CACERT", "VAULT_CAPATH", "VAULT_SKIP_VERIFY"} {
+		value, ok := os.LookupEnv(name)
+		name := name
+		t.Cleanup(func() {
+			if ok {
+				_ = os.Setenv(name, value)
+			} else {
+				_ = os.Unsetenv(name)
+			}
+		})
+		_ = os.Unsetenv(name)
+	}
+	client, err := NewClientFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.Address != DefaultAddress {
+		t.Error("unexpected default address", client.Address)
+	}
+	_ = os.Setenv("VAULT_ADDR", "https://vault.example.com/")
+	_ = os.Setenv("VAULT_TOKEN", "token")
+	_ = os.Setenv("VAULT_NAMESPACE", "team")
+	_ = os.Setenv("VAULT_SKIP_VERIFY", "true")
+	client, err = NewClientFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.Address != "https://vault.example.com" || client.Token != "token" || client.Namespace != "team" {
+		t.Error("unexpected client", client)
+	}
+	if !client.HTTP.Transport.(*http.Transport).TLSClientConfig.InsecureSkipVerify {
+		t.Error("expected tls verification to be skipped")
+	}
+	_ = os.Setenv("VAULT_SKIP_VERIFY", "maybe")
+	_, err = NewClientFromEnv()
+	if err == nil {
+		t.Error("expected an error for an invalid VAULT_SKIP_VERIFY")
+	}
+	_ = os.Unsetenv("VAULT_SKIP_VERIFY")
+	_ = os.Setenv("VAULT_CACERT", "does-not-exist.pem")
+	_, err = NewClientFromEnv()
+	if err == nil {
+		t.Error("expected an error for a missing ca certificate")
+	}
+}
+
+func TestSetDefaultEnv(t *testing.T) {
+	for _, name := range []string{"VAULT_TOKEN", "VAULT_NAMESPACE", "VAULT_CACERT", "VAULT_CAPATH", "VAULT_SKIP_VERIFY", "VAULT_AUTH_METHOD", "VAULT_ROLE_ID", "VAULT_SECRET_ID"} {
+		t.Setenv(name, "")
+	}
+	v, testClient := newTestVault(t, nil)
+	t.Setenv("VAULT_ADDR", testClient.Address)
+	t.Setenv("VAULT_TOKEN", testToken)
+	t.Cleanup(func() {
+		SetDefaultEnv(nil)
+	})
+	SetDefaultEnv(map[string]string{
+		"VAULT_NAMESPACE":   "team",
+		"VAULT_AUTH_METHOD": "approle",
+		"VAULT_ROLE_ID":     "role",
+		"VAULT_SECRET_ID":   "secret",
+	})
+	client, err := DefaultClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.Namespace != "team" || client.Token != "login-token-1" {
+		t.Error("unexpected client", client.Namespace, client.Token)
+	}
+	if os.Getenv("VAULT_NAMESPACE") != "" || os.Getenv("VAULT_AUTH_METHOD") != "" {
+		t.Error("the environment has been changed")
+	}
+	// the settings of the last call are not kept
+	SetDefaultEnv(map[string]string{})
+	client, err = DefaultClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.Namespace != "" || client.Token != testToken {
+		t.Error("unexpected client", client.Namespace, client.Token)
+	}
+	if v.logins != 1 || !reflect.DeepEqual(v.revoked, []string{"login-token-1"}) {
+		t.Error("the token of the replaced client has not been revoked", v.logins, v.revoked)
+	}
+}
+
+func TestClientKV2(t *testing.T) {
+	v, client := newTestVault(t, map[string]map[string]interface{}{
+		"kv1/app/db": {"user": "v1"},
+	})
+	v.mounts = map[string]int{"secret/": 2, "kv1/": 1}
+	v.versions = map[string][]map[string]interface{}{
+		"secret/app/db":      {{"user": "first"}, {"user": "second"}},
+		"secret/app/cache/a": {{"user": "a"}},
+	}
+	secret, err := client.Read("secret/app/db")
+	if err != nil || secret["user"] != "second" {
+		t.Error("expected the latest version", secret, err)
+	}
+	secret, err = client.ReadVersion("secret/app/db", 1)
+	if err != nil || secret["user"] != "first" {
+		t.Error("expected the first version", secret, err)
+	}
+	_, err = client.ReadVersion("secret/app/db", 3)
+	if !errors.Is(err, ErrNotFound) {
+		t.Error("expected not found, got", err)
+	}
+	secret, err = client.Read("kv1/app/db")
+	if err != nil || secret["user"] != "v1" {
+		t.Error("expected kv version 1 secret", secret, err)
+	}
+	_, err = client.ReadVersion("kv1/app/db", 1)
+	if err == nil {
+		t.Error("expected an error for a version of a kv version 1 secret")
+	}
+	data, err := client.tree("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]map[string]interface{}{
+		"secret/app/db":      {"user": "second"},
+		"secret/app/cache/a": {"user": "a"},
+	}
+	if !reflect.DeepEqual(data, expected) {
+		t.Error("unexpected tree", data)
+	}
+}
+
+func TestClientMountLookupErrors(t *testing.T) {
+	v, client := newTestVault(t, nil)
+	v.mounts = map[string]int{"secret/": 2}
+	v.versions = map[string][]map[string]interface{}{"secret/app/db": {{"user": "app"}}}
+	v.sealed = true
+	if _, err := client.Read("secret/app/db"); !errors.Is(err, ErrSealed) {
+		t.Error("expected a sealed vault, got", err)
+	}
+	if len(client.mounts) != 0 {
+		t.Error("failed mount lookups should not be cached", client.mounts)
+	}
+	v.sealed = false
+	secret, err := client.Read("secret/app/db")
+	if err != nil || secret["user"] != "app" {
+		t.Error("expected the kv version 2 secret after the vault has been unsealed", secret, err)
+	}
+}
+
+func TestClientMountLongestPrefix(t *testing.T) {
+	_, client := newTestVault(t, nil)
+	kv2 := &mount{path: "secret/", version: 2}
+	// cached after a 403 or 404, without a path
+	own := &mount{version: 1}
+	client.mounts = map[string]*mount{"secret/": kv2, "secret/app/": own}
+	// the order of the map is random
+	for i := 0; i < 20; i++ {
+		if m := client.getMount("secret/app/db"); m != own {
+			t.Fatalf("getMount(secret/app/db) = %#v, want %#v", m, own)
+		}
+		if m := client.getMount("secret/other"); m != kv2 {
+			t.Fatalf("getMount(secret/other) = %#v, want %#v", m, kv2)
+		}
+	}
+}
+
+func TestClientTypedValues(t *testing.T) {
+	_, client := newTestVault(t, map[string]map[string]interface{}{
+		"secret/app": {
+			"port":    8080,
+			"ratio":   0.5,
+			"enabled": true,
+			"hosts":   []string{"a", "b"},
+			"db":      map[string]interface{}{"user": "app", "pool": 10},
+		},
+	})
+	secret, err := client.Read("secret/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"port":    int64(8080),
+		"ratio":   0.5,
+		"enabled": true,
+		"hosts":   []interface{}{"a", "b"},
+		"db":      map[string]interface{}{"user": "app", "pool": int64(10)},
+	}
+	if !reflect.DeepEqual(secret, expected) {
+		t.Errorf("unexpected secret %#v", secret)
+	}
+	for value, expected := range map[interface{}]string{
+		"plain":      "plain",
+		int64(8080):  "8080",
+		0.5:          "0.5",
+		true:         "true",
+		"with\nline": "with\nline",
+	} {
+		if s := ValueString(value); s != expected {
+			t.Errorf("ValueString(%#v) = %q, want %q", value, s, expected)
+		}
+	}
+	if s := ValueString(expected["db"]); s != `{"pool":10,"user":"app"}` {
+		t.Error("unexpected json", s)
+	}
+}
@@ -38,13 +38,13 @@ func writeHtpasswdFiles(config HtpasswdConfig, hashAlgorithm htpasswd.HashAlgori
 			if err != nil {
 				return fmt.Errorf("could not read secret for path %q got error:: %q", passwordVaultPath, err)
 			}
-			user, userOk := secret["user"]
-			password, passwordOk := secret["password"]
+			user, userOk := secret["user"].(string)
+			password, passwordOk := secret["password"].(string)
 			if !userOk {
-				return fmt.Errorf("secret from path %q is missing key user", passwordVaultPath)
+				return fmt.Errorf("secret from path %q is missing key user or it is not a string", passwordVaultPath)
 			}
 			if !passwordOk {
-				return fmt.Errorf("secret from path %q is missing key password", passwordVaultPath)
+				return fmt.Errorf("secret from path %q is missing key password or it is not a string", passwordVaultPath)
 			}
 			fmt.Println("	", passwordVaultPath, ":", user)
 			err = htpasswd.SetPassword(passwordFile, user, password, hashAlgorithm)
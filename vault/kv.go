@@ -0,0 +1,114 @@
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// mount of a path and the version of its kv secrets engine
+type mount struct {
+	path    string
+	version int
+}
+
+// getMount looks up the mount of a path and caches it. Vaults, that do not tell, are treated as kv version 1. Other
+// errors like a sealed vault are not cached, the mount is looked up again next time.
+func (c *Client) getMount(path string) *mount {
+	path = strings.Trim(path, "/") + "/"
+	c.mountsLock.Lock()
+	defer c.mountsLock.Unlock()
+	// the longest prefix wins, mounts cached for paths, that stand for themselves, have no path to compare
+	var found *mount
+	foundLen := 0
+	for prefix, m := range c.mounts {
+		if strings.HasPrefix(path, prefix) && (found == nil || len(prefix) > foundLen) {
+			found, foundLen = m, len(prefix)
+		}
+	}
+	if found != nil {
+		return found
+	}
+	if c.mounts == nil {
+		c.mounts = map[string]*mount{}
+	}
+	response := &struct {
+		Data struct {
+			Path    string            `json:"path"`
+			Type    string            `json:"type"`
+			Options map[string]string `json:"options"`
+		} `json:"data"`
+	}{}
+	err := c.do(http.MethodGet, "sys/internal/ui/mounts/"+path, nil, response)
+	if err != nil && !errors.Is(err, ErrForbidden) && !errors.Is(err, ErrNotFound) {
+		return &mount{version: 1}
+	}
+	if err != nil || response.Data.Path == "" {
+		// old vaults or tokens without access to the mount information, the path stands for itself
+		found = &mount{version: 1}
+		c.mounts[path] = found
+		return found
+	}
+	found = &mount{path: strings.Trim(response.Data.Path, "/") + "/", version: 1}
+	if response.Data.Type == "kv" && response.Data.Options["version"] == "2" {
+		found.version = 2
+	}
+	c.mounts[found.path] = found
+	return found
+}
+
+// apiPath inserts the segment after the mount of kv version 2 paths
+func (m *mount) apiPath(path, segment string) string {
+	if m.version != 2 {
+		return path
+	}
+	rest := strings.TrimPrefix(strings.Trim(path, "/")+"/", m.path)
+	return m.path + segment + "/" + strings.TrimSuffix(rest, "/")
+}
+
+// ReadVersion reads a version of a secret from a kv version 2 mount, 0 reads the latest version and is the only
+// version, that secrets from other mounts have
+func (c *Client) ReadVersion(path string, version int) (secret map[string]interface{}, err error) {
+	m := c.getMount(path)
+	if m.version != 2 {
+		if version != 0 {
+			return nil, fmt.Errorf("can not read version %d of %q, versions need a kv version 2 mount", version, path)
+		}
+		response := &readResponse{}
+		err = c.do(http.MethodGet, path, nil, response)
+		if err != nil {
+			return nil, err
+		}
+		return normalizeSecret(response.Data), nil
+	}
+	apiPath := m.apiPath(path, "data")
+	if version != 0 {
+		apiPath += "?version=" + strconv.Itoa(version)
+	}
+	response := &struct {
+		Data readResponse `json:"data"`
+	}{}
+	err = c.do(http.MethodGet, apiPath, nil, response)
+	if err != nil {
+		return nil, err
+	}
+	if response.Data.Data == nil {
+		// deleted and destroyed versions have metadata, but no data
+		return nil, &ResponseError{Method: http.MethodGet, Path: apiPath, StatusCode: http.StatusNotFound, Errors: []string{"version has been deleted"}}
+	}
+	return normalizeSecret(response.Data.Data), nil
+}
+
+// ReadVersion reads a version of a secret with the default client
+func ReadVersion(path string, version int) (secret map[string]interface{}, err error) {
+	if Dummy {
+		return Read(path)
+	}
+	client, err := DefaultClient()
+	if err != nil {
+		return nil, err
+	}
+	return client.ReadVersion(path, version)
+}
@@ -1,9 +1,7 @@
 package vault
 
 import (
-	"encoding/json"
 	"fmt"
-	"os/exec"
 	"strings"
 )
 
@@ -17,7 +15,7 @@ func Tree(path string) error {
 	for p, d := range data {
 		fmt.Printf("\n\n%s", p)
 		for k, v := range d {
-			v := strings.Replace(v, "\n", "\\n", -1)
+			v := strings.Replace(ValueString(v), "\n", "\\n", -1)
 			fmt.Printf("\n\t%s=%s", k, v)
 		}
 	}
@@ -25,30 +23,28 @@ func Tree(path string) error {
 	return nil
 }
 
-func tree(path string) (map[string]map[string]string, error) {
-	path = strings.TrimSuffix(path, "/")
-	cmd := exec.Command("vault", "list", "-format", "json", path)
-	jsonBytes, err := cmd.CombinedOutput()
+func tree(path string) (map[string]map[string]interface{}, error) {
+	client, err := DefaultClient()
 	if err != nil {
-		return nil, vaultErr(jsonBytes, err)
-	}
-	var paths []string
-	if string(jsonBytes) == "No entries found\n" {
-		// thank you for the json
-		return nil, nil
+		return nil, err
 	}
-	err = json.Unmarshal(jsonBytes, &paths)
+	return client.tree(path)
+}
+
+func (c *Client) tree(path string) (map[string]map[string]interface{}, error) {
+	path = strings.TrimSuffix(path, "/")
+	paths, err := c.List(path)
 	if err != nil {
 		return nil, err
 	}
 
-	vaultData := map[string]map[string]string{}
+	vaultData := map[string]map[string]interface{}{}
 	for _, p := range paths {
 		current := fmt.Sprintf("%s/%s", strings.TrimSuffix(path, "/"), strings.TrimPrefix(p, "/"))
 		if strings.HasSuffix(p, "/") {
 			path := path + "/" + p[:len(p)-1]
 
-			data, err := tree(path)
+			data, err := c.tree(path)
 			if err != nil {
 				return nil, err
 			}
@@ -57,11 +53,11 @@ func tree(path string) (map[string]map[string]string, error) {
 			}
 
 		} else {
-			data, err := Read(current)
+			data, err := c.Read(current)
 			if err != nil {
 				return nil, err
 			}
-			vaultData[current] = map[string]string{}
+			vaultData[current] = map[string]interface{}{}
 			for key, value := range data {
 				vaultData[current][key] = value
 			}
@@ -13,7 +13,7 @@ import (
 const vaultAddr = "127.0.0.1:8200"
 
 type readResponse struct {
-	Data map[string]string
+	Data map[string]interface{} `json:"data"`
 }
 
 type Version struct {
@@ -39,6 +39,9 @@ func (v Version) LowerThan(t Version) bool {
 
 var vaultVersionCommand = exec.Command("vault", "-v")
 
+// GetUnsealCommand returns a command for the vault command line program to enter a key
+//
+// Deprecated: use Unseal, which does not need the vault command line program
 func GetUnsealCommand(vaultKey string) (*exec.Cmd, error) {
 	version, err := GetVaultVersionParsed()
 	if err != nil {
@@ -88,17 +91,13 @@ func GetVaultVersionParsed() (version Version, err error) {
 	return Version{versionData[0], versionData[1], versionData[2]}, nil
 }
 
-func vaultErr(combinedOutput []byte, err error) error {
-	return fmt.Errorf("err: %q, output: %q", err, string(combinedOutput))
-}
-
 // VaultDummy enables a built in dummy
 var Dummy = false
 
-// Read data from a vault - env vars need to be set
-func Read(path string) (secret map[string]string, err error) {
+// Read data from a vault with the default client - env vars need to be set
+func Read(path string) (secret map[string]interface{}, err error) {
 	if Dummy {
-		return map[string]string{
+		return map[string]interface{}{
 			"token":    "well-a-token",
 			"name":     "call my name",
 			"user":     "user-from" + path,
@@ -107,15 +106,48 @@ func Read(path string) (secret map[string]string, err error) {
 		}, nil
 	}
 
-	jsonBytes, err := exec.Command("vault", "read", "-format", "json", path).CombinedOutput()
+	client, err := DefaultClient()
 	if err != nil {
-		return nil, vaultErr(jsonBytes, err)
+		return nil, err
 	}
-	response := &readResponse{}
-	jsonErr := json.Unmarshal(jsonBytes, response)
-	if jsonErr != nil {
-		return nil, jsonErr
+	return client.Read(path)
+}
+
+// normalizeSecret turns the json numbers of a decoded secret into int64 values, if they are integers and float64
+// values otherwise
+func normalizeSecret(secret map[string]interface{}) map[string]interface{} {
+	for key, value := range secret {
+		secret[key] = normalizeNumbers(value)
+	}
+	return secret
+}
+
+func normalizeNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		return normalizeSecret(v)
+	case []interface{}:
+		for i, element := range v {
+			v[i] = normalizeNumbers(element)
+		}
 	}
+	return value
+}
 
-	return response.Data, nil
+// ValueString returns strings as they are and json for all other values of a secret
+func ValueString(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	jsonBytes, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(jsonBytes)
 }